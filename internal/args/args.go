@@ -15,6 +15,9 @@ type Flags struct {
 	ShowUpdates    bool
 	ShowInstalled  bool
 	Help           bool
+	Json           bool
+	Command        string
+	CommandArgs    []string
 }
 
 // Parse is parsing our arguments and creates a Flags struct from it
@@ -25,6 +28,7 @@ func Parse() Flags {
 	mono := getopt.Bool('m', "Monochrome mode")
 	upd := getopt.Bool('u', "Show updates after startup")
 	inst := getopt.Bool('i', "Show installed packages after startup")
+	json := getopt.BoolLong("json", 0, "Run a query and print the results as JSON")
 	help := getopt.BoolLong("help", 'h', "Show usage / help")
 	qhelp := getopt.BoolLong("?", '?', "Show usage / help")
 
@@ -41,6 +45,7 @@ func Parse() Flags {
 		MonochromeMode: *mono,
 		ShowUpdates:    *upd,
 		ShowInstalled:  *inst,
+		Json:           *json,
 	}
 
 	if len(*repos) > 0 {
@@ -49,6 +54,20 @@ func Parse() Flags {
 
 	flags.Help = *help || *qhelp
 
+	// query mode: first argument is the command, the rest are its arguments
+	if flags.Json {
+		if len(getopt.Args()) == 0 {
+			flags.Help = true
+			return flags
+		}
+		flags.Command = getopt.Args()[0]
+		flags.CommandArgs = getopt.Args()[1:]
+		if flags.Command == "search" && len(flags.CommandArgs) == 0 && flags.SearchTerm != "" {
+			flags.CommandArgs = []string{flags.SearchTerm}
+		}
+		return flags
+	}
+
 	if flags.SearchTerm == "" && len(getopt.Args()) > 0 {
 		flags.SearchTerm = getopt.Args()[0]
 	}
@@ -8,40 +8,62 @@ import (
 
 // Settings is a structure containing our configuration data
 type Settings struct {
-	AurRpcUrl               string
-	AurTimeout              int
-	AurSearchDelay          int
-	AurUseDifferentCommands bool
-	AurInstallCommand       string
-	AurUpgradeCommand       string
-	DisableAur              bool
-	MaxResults              int
-	PacmanDbPath            string
-	PacmanConfigPath        string
-	InstallCommand          string
-	UninstallCommand        string
-	SysUpgradeCommand       string
-	SearchMode              string
-	SearchBy                string
-	CacheExpiry             int
-	DisableCache            bool
-	ColorScheme             string
-	BorderStyle             string
-	ShowPkgbuildCommand     string
-	ShowPkgbuildInternally  bool
-	ComputeRequiredBy       bool
-	GlyphStyle              string
-	DisableNewsFeed         bool
-	FeedURLs                string
-	FeedMaxItems            int
-	SaveWindowLayout        bool
-	LeftProportion          int
-	Transparent             bool
-	PackageColumnWidth      int
-	EnableAutoSuggest       bool
-	SepDepsWithNewLine      bool
-	colors                  Colors
-	glyphs                  Glyphs
+	AurRpcUrl                 string
+	AurTimeout                int
+	AurSearchDelay            int
+	AurUseDifferentCommands   bool
+	AurInstallCommand         string
+	AurUpgradeCommand         string
+	DisableAur                bool
+	AurDataSource             string
+	AurDumpUrl                string
+	AurDumpRefresh            int
+	MaxResults                int
+	PacmanDbPath              string
+	PacmanConfigPath          string
+	InstallCommand            string
+	UninstallCommand          string
+	InstallReasonCommand      string
+	DisableTransactionPreview bool
+	AurBuildInternally        bool
+	AurCleanBuild             bool
+	DisablePkgbuildLinter     bool
+	PkgbuildRules             []PkgbuildRule
+	SysUpgradeCommand         string
+	SearchMode                string
+	SearchBy                  string
+	CacheExpiry               int
+	DisableCache              bool
+	EnableDiskCache           bool
+	ColorScheme               string
+	BorderStyle               string
+	ShowPkgbuildCommand       string
+	ShowPkgbuildInternally    bool
+	ComputeRequiredBy         bool
+	GlyphStyle                string
+	DisableNewsFeed           bool
+	FeedURLs                  string
+	FeedMaxItems              int
+	SaveWindowLayout          bool
+	LeftProportion            int
+	Transparent               bool
+	PackageColumnWidth        int
+	PackageListColumns        []string
+	EnableAutoSuggest         bool
+	SepDepsWithNewLine        bool
+	PackageSources            []string
+	colors                    Colors
+	glyphs                    Glyphs
+}
+
+// PkgbuildRule is a rule for our PKGBUILD linter. Rules with the ID of a built-in rule replace it
+type PkgbuildRule struct {
+	ID          string
+	Description string
+	Severity    string // low, medium or high
+	Pattern     string // regular expression matched against each line; an empty pattern disables a rule
+	Scope       string `json:",omitempty"` // regular expression for the function / array names a line has to be in
+	Exclude     string `json:",omitempty"` // regular expression for lines that should be ignored
 }
 
 // Defaults returns the default settings
@@ -51,16 +73,21 @@ func Defaults() *Settings {
 		AurTimeout:             5000,
 		AurSearchDelay:         500,
 		DisableAur:             false,
+		AurDataSource:          "RPC",
+		AurDumpUrl:             "https://aur.archlinux.org/packages-meta-ext-v1.json.gz",
+		AurDumpRefresh:         24,
 		MaxResults:             500,
 		PacmanDbPath:           "/var/lib/pacman/",
 		PacmanConfigPath:       "/etc/pacman.conf",
 		InstallCommand:         "yay -S",
 		UninstallCommand:       "yay -Rs",
+		InstallReasonCommand:   "sudo pacman -D {reason} {pkg}",
 		SearchMode:             "Contains",
 		SysUpgradeCommand:      "yay",
 		SearchBy:               "Name",
 		CacheExpiry:            10,
 		DisableCache:           false,
+		EnableDiskCache:        false,
 		ColorScheme:            defaultColorScheme,
 		BorderStyle:            "Double",
 		colors:                 colorSchemes[defaultColorScheme],
@@ -76,8 +103,10 @@ func Defaults() *Settings {
 		LeftProportion:         4,
 		Transparent:            false,
 		PackageColumnWidth:     0,
+		PackageListColumns:     []string{"Name", "Source", "Installed"},
 		EnableAutoSuggest:      false,
 		SepDepsWithNewLine:     true,
+		PackageSources:         []string{"Repositories", "AUR"},
 	}
 
 	return &s
@@ -200,6 +229,38 @@ func (s *Settings) applyUpgradeFixes() {
 		fixApplied = true
 	}
 
+	// Package sources added with 1.8.7
+	if len(s.PackageSources) == 0 {
+		s.PackageSources = def.PackageSources
+		fixApplied = true
+	}
+
+	// AUR dump added with 1.8.7
+	if s.AurDataSource == "" {
+		s.AurDataSource = def.AurDataSource
+		fixApplied = true
+	}
+	if s.AurDumpUrl == "" {
+		s.AurDumpUrl = def.AurDumpUrl
+		fixApplied = true
+	}
+	if s.AurDumpRefresh < 1 {
+		s.AurDumpRefresh = def.AurDumpRefresh
+		fixApplied = true
+	}
+
+	// Install reason command added with 1.8.7
+	if s.InstallReasonCommand == "" {
+		s.InstallReasonCommand = def.InstallReasonCommand
+		fixApplied = true
+	}
+
+	// Package list columns added with 1.8.7
+	if len(s.PackageListColumns) == 0 {
+		s.PackageListColumns = def.PackageListColumns
+		fixApplied = true
+	}
+
 	// save config file when we applied changes
 	if fixApplied {
 		s.Save()
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// WatchedPackage is a package on our watchlist along with its state at the last check
+type WatchedPackage struct {
+	Name       string
+	Source     string
+	Version    string
+	Maintainer string
+	OutOfDate  int
+	Deleted    bool
+}
+
+// Watchlist is a list of packages we want to be notified about when they change
+type Watchlist struct {
+	Packages []WatchedPackage
+}
+
+// returns the path of our watchlist file ~/.config/pacseek/watchlist.json
+func watchlistPath() (string, error) {
+	confPath, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(confPath, "/pacseek/watchlist.json"), nil
+}
+
+// LoadWatchlist is loading our watchlist; an empty one is returned if it does not exist yet
+func LoadWatchlist() (*Watchlist, error) {
+	file, err := watchlistPath()
+	if err != nil {
+		return nil, err
+	}
+	w := Watchlist{Packages: []WatchedPackage{}}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Save is creating / overwriting our watchlist file
+func (w *Watchlist) Save() error {
+	b, err := json.MarshalIndent(w, "", "	")
+	if err != nil {
+		return err
+	}
+	file, err := watchlistPath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0644)
+}
+
+// Index returns the position of a package on the watchlist or -1 if it is not being watched
+func (w *Watchlist) Index(name, source string) int {
+	for i, p := range w.Packages {
+		if p.Name == name && p.Source == source {
+			return i
+		}
+	}
+	return -1
+}
+
+// Toggle adds a package to the watchlist or removes it if it is already being watched.
+// It returns true if the package has been added
+func (w *Watchlist) Toggle(pkg WatchedPackage) bool {
+	if i := w.Index(pkg.Name, pkg.Source); i != -1 {
+		w.Packages = append(w.Packages[:i], w.Packages[i+1:]...)
+		return false
+	}
+	w.Packages = append(w.Packages, pkg)
+	return true
+}
@@ -1,6 +1,7 @@
 package pacseek
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -11,18 +12,46 @@ import (
 )
 
 // calls the AUR rpc API (suggest type) and returns found packages (beginning with "term")
-func searchAur(aurUrl, term string, timeout int, mode string, by string, maxResults int) ([]Package, error) {
+func searchAur(ctx context.Context, aurUrl, term string, timeout int, mode string, by string, maxResults int) ([]Package, error) {
 	packages := []Package{}
-	client := http.Client{
-		Timeout: time.Millisecond * time.Duration(timeout),
+	q, err := parseSearchQuery(term)
+	if err != nil {
+		return packages, err
+	}
+	q.applySearchBy(by)
+	if !q.allowsSource(true) {
+		return packages, nil
+	}
+	match, err := newPackageMatcher(q.Term, mode, by)
+	if err != nil {
+		return packages, err
 	}
 
+	// search by term or, if we don't have one, look up packages by maintainer / provides / depends
 	t := "search"
-	if by == "Name" {
-		t = "search&by=name"
+	var aurTerm string
+	if q.Term != "" {
+		aurTerm, err = aurSearchTerm(q.Term, mode)
+		if err != nil {
+			return packages, err
+		}
+		if by == "Name" {
+			t = "search&by=name"
+		}
+	} else if f, ok := q.lookupFilter(); ok {
+		aurTerm = f.Value
+		t = "search&by=" + f.Field
+		// the AUR did the filtering for us already
+		q.Filters = removeFilter(q.Filters, f)
+	} else {
+		return packages, nil
 	}
 
-	req, err := http.NewRequest("GET", aurUrl+"?v=5&type="+t+"&arg="+url.QueryEscape(term), nil)
+	client := http.Client{
+		Timeout: time.Millisecond * time.Duration(timeout),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", aurUrl+"?v=5&type="+t+"&arg="+url.QueryEscape(aurTerm), nil)
 	if err != nil {
 		return packages, err
 	}
@@ -51,22 +80,46 @@ func searchAur(aurUrl, term string, timeout int, mode string, by string, maxResu
 		return s.Results[i].Name < s.Results[j].Name
 	})
 
-	i := 0
+	records := []InfoRecord{}
 	for _, pkg := range s.Results {
+		if q.Term == "" || match.matches(pkg.Name, pkg.Description) {
+			pkg.Source = "AUR"
+			records = append(records, pkg)
+		}
+	}
+
+	// search results don't contain dependencies, licenses, etc.
+	if q.needsDetails() && len(records) > 0 {
+		names := []string{}
+		for _, pkg := range records {
+			names = append(names, pkg.Name)
+		}
+		info := infoAur(ctx, aurUrl, timeout, names...)
+		if info.Error != "" {
+			return packages, errors.New(info.Error)
+		}
+		records = info.Results
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].Name < records[j].Name
+		})
+	}
+
+	for _, pkg := range records {
 		// filter records
-		if (mode == "StartsWith" && by == "Name" && strings.HasPrefix(pkg.Name, term)) ||
-			(mode == "StartsWith" && by != "Name" && (strings.HasPrefix(pkg.Name, term) || strings.HasPrefix(strings.ToLower(pkg.Description), term))) ||
-			mode == "Contains" {
+		if q.matches(pkg) {
 			packages = append(packages, Package{
 				Name:         pkg.Name,
 				Source:       "AUR",
 				LastModified: pkg.LastModified,
 				Popularity:   pkg.Popularity,
+				Version:      pkg.Version,
+				NumVotes:     pkg.NumVotes,
+				OutOfDate:    pkg.OutOfDate,
+				Maintainer:   pkg.Maintainer,
 			})
 			if len(packages) >= maxResults {
 				break
 			}
-			i++
 		}
 	}
 
@@ -74,7 +127,7 @@ func searchAur(aurUrl, term string, timeout int, mode string, by string, maxResu
 }
 
 // calls the AUR rpc API (info type) and returns package information
-func infoAur(aurUrl string, timeout int, pkg ...string) SearchResults {
+func infoAur(ctx context.Context, aurUrl string, timeout int, pkg ...string) SearchResults {
 	client := http.Client{
 		Timeout: time.Millisecond * time.Duration(timeout),
 	}
@@ -86,7 +139,7 @@ func infoAur(aurUrl string, timeout int, pkg ...string) SearchResults {
 		data.Add("arg[]", p)
 	}
 
-	req, err := http.NewRequest("POST", aurUrl, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", aurUrl, strings.NewReader(data.Encode()))
 	if err != nil {
 		return SearchResults{Error: err.Error()}
 	}
@@ -114,13 +167,13 @@ func infoAur(aurUrl string, timeout int, pkg ...string) SearchResults {
 }
 
 // calls the AUR rpc API (suggest type) and returns package names
-func suggestAur(aurUrl, term string, timeout int) []string {
+func suggestAur(ctx context.Context, aurUrl, term string, timeout int) []string {
 	packages := []string{}
 	client := http.Client{
 		Timeout: time.Millisecond * time.Duration(timeout),
 	}
 
-	req, err := http.NewRequest("GET", aurUrl+"?v=5&type=suggest&arg="+url.PathEscape(term), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", aurUrl+"?v=5&type=suggest&arg="+url.PathEscape(term), nil)
 	if err != nil {
 		return packages
 	}
@@ -0,0 +1,288 @@
+package pacseek
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moson-mo/pacseek/internal/util"
+)
+
+// AUR data sources
+const (
+	aurSourceRpc          = "RPC"
+	aurSourceDump         = "Dump"
+	aurSourceDumpFallback = "Dump with RPC fallback"
+)
+
+// maximum time for downloading the AUR dump and how long we wait before trying again after a failure
+const (
+	aurDumpTimeout    = 10 * time.Minute
+	aurDumpRetryDelay = 5 * time.Minute
+)
+
+// returns the available data sources for the AUR
+func aurDataSources() []string {
+	return []string{aurSourceRpc, aurSourceDump, aurSourceDumpFallback}
+}
+
+// aurDump is a local index built from the AUR metadata dump
+type aurDump struct {
+	url     string
+	refresh time.Duration
+
+	mut      sync.Mutex
+	records  []InfoRecord
+	byName   map[string]int
+	loadedAt time.Time
+	loading  chan struct{} // closed once the running update is finished; nil if there is none
+	err      error         // error of the last update
+	failedAt time.Time
+}
+
+// creates a new (empty) index for the dump file at url
+func newAurDump(url string, refresh time.Duration) *aurDump {
+	return &aurDump{
+		url:     url,
+		refresh: refresh,
+		byName:  map[string]int{},
+	}
+}
+
+// starts updating the index in the background if it's not loaded yet or older than our refresh interval.
+// It returns a channel that is closed once the update is finished; nil if there is nothing to do.
+// After a failed update we wait a while before trying again
+func (d *aurDump) update() <-chan struct{} {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	switch {
+	case d.loading != nil:
+		return d.loading
+	case !d.loadedAt.IsZero() && time.Since(d.loadedAt) < d.refresh:
+		return nil
+	case !d.failedAt.IsZero() && time.Since(d.failedAt) < aurDumpRetryDelay:
+		return nil
+	}
+
+	done := make(chan struct{})
+	d.loading = done
+	go func() {
+		err := d.load()
+
+		d.mut.Lock()
+		defer d.mut.Unlock()
+		d.err, d.failedAt = err, time.Time{}
+		if err != nil {
+			d.failedAt = time.Now()
+		}
+		d.loading = nil
+		close(done)
+	}()
+	return done
+}
+
+// checks if we can use the index; an outdated one is used until the update is finished
+func (d *aurDump) ready() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	switch {
+	case !d.loadedAt.IsZero():
+		return nil
+	case d.err != nil:
+		return d.err
+	}
+	return errors.New("AUR dump is not loaded yet")
+}
+
+// downloads (if necessary) and parses the dump file and replaces our index with it.
+// The download is not bound to the request that triggered it, others are waiting for it as well
+func (d *aurDump) load() error {
+	ctx, cancel := context.WithTimeout(context.Background(), aurDumpTimeout)
+	defer cancel()
+	file, err := d.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	records, err := readAurDump(file)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+	byName := make(map[string]int, len(records))
+	for i := 0; i < len(records); i++ {
+		records[i].Source = "AUR"
+		byName[records[i].Name] = i
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.records = records
+	d.byName = byName
+	d.loadedAt = time.Now()
+	return nil
+}
+
+// returns the path to an up-to-date dump file, downloading it if necessary
+func (d *aurDump) fetch(ctx context.Context) (string, error) {
+	// local files are used as they are
+	if !strings.HasPrefix(d.url, "http://") && !strings.HasPrefix(d.url, "https://") {
+		return strings.TrimPrefix(d.url, "file://"), nil
+	}
+
+	dir, err := util.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	file := path.Join(dir, "aur-"+path.Base(d.url))
+	if fi, err := os.Stat(file); err == nil && time.Since(fi.ModTime()) < d.refresh {
+		return file, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", d.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "pacseek/"+version)
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download AUR dump: %s", r.Status)
+	}
+
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return file, os.Rename(tmp, file)
+}
+
+// parses a (gzip compressed) dump file
+func readAurDump(file string) ([]InfoRecord, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	records := []InfoRecord{}
+	if err = json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, errors.New("failed to parse AUR dump: " + err.Error())
+	}
+	return records, nil
+}
+
+// searches the index, equivalent to our AUR RPC search
+func (d *aurDump) search(term, mode, by string, maxResults int) ([]Package, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	packages := []Package{}
+	q, err := parseSearchQuery(term)
+	if err != nil {
+		return packages, err
+	}
+	q.applySearchBy(by)
+	if !q.allowsSource(true) {
+		return packages, nil
+	}
+	match, err := newPackageMatcher(q.Term, mode, by)
+	if err != nil {
+		return packages, err
+	}
+
+	for _, pkg := range d.records {
+		if len(packages) >= maxResults {
+			break
+		}
+		if (q.Term == "" || match.matches(pkg.Name, pkg.Description)) && q.matches(pkg) {
+			packages = append(packages, Package{
+				Name:         pkg.Name,
+				Source:       "AUR",
+				LastModified: pkg.LastModified,
+				Popularity:   pkg.Popularity,
+				Version:      pkg.Version,
+				NumVotes:     pkg.NumVotes,
+				OutOfDate:    pkg.OutOfDate,
+				Maintainer:   pkg.Maintainer,
+			})
+		}
+	}
+	return packages, nil
+}
+
+// returns package information for the given package names; the second return value contains names not found
+func (d *aurDump) info(pkgs ...string) (SearchResults, []string) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	sr := SearchResults{
+		Results: []InfoRecord{},
+	}
+	notFound := []string{}
+	for _, pkg := range pkgs {
+		if i, ok := d.byName[pkg]; ok {
+			sr.Results = append(sr.Results, d.records[i])
+		} else {
+			notFound = append(notFound, pkg)
+		}
+	}
+	sr.Resultcount = len(sr.Results)
+	return sr, notFound
+}
+
+// returns package names starting with term, equivalent to the AUR RPC suggest call
+func (d *aurDump) suggest(term string) []string {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	names := []string{}
+	start := sort.Search(len(d.records), func(i int) bool {
+		return d.records[i].Name >= term
+	})
+	for i := start; i < len(d.records) && len(names) < 20; i++ {
+		if !strings.HasPrefix(d.records[i].Name, term) {
+			break
+		}
+		names = append(names, d.records[i].Name)
+	}
+	return names
+}
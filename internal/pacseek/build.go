@@ -0,0 +1,509 @@
+package pacseek
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/rivo/tview"
+)
+
+const (
+	buildRef       = "refs/pacseek/built"                       // git ref pointing to the last commit we've built
+	gitEmptyTree   = "4b825dc642cb6eb9a060e54bf8d69288fbee4904" // diffing against it shows all files
+	sudoKeepAlive  = time.Minute
+	aurGitURL      = "https://aur.archlinux.org/%s.git"
+	buildDirectory = "build"
+)
+
+// buildPackage is a package base from the AUR that we build with makepkg
+type buildPackage struct {
+	Base    string
+	Targets []string // packages that are being installed explicitly
+	Deps    []string // packages that are being installed as dependencies
+
+	requires []string // package bases that have to be built first
+}
+
+// returns the package bases that need to be built in order to install some AUR packages.
+// Dependencies are built first; missing dependencies and cycles are reported with an error
+func aurBuildOrder(trees []*depNode) ([]buildPackage, error) {
+	bases := map[string]*buildPackage{}
+	found := []string{}
+	missing := []string{}
+
+	add := func(r InfoRecord, target bool) *buildPackage {
+		base := r.PackageBase
+		if base == "" {
+			base = r.Name
+		}
+		b, ok := bases[base]
+		if !ok {
+			b = &buildPackage{Base: base}
+			bases[base] = b
+			found = append(found, base)
+		}
+		switch {
+		case util.SliceContains(b.Targets, r.Name):
+		case target:
+			b.Targets = append(b.Targets, r.Name)
+			b.Deps = removeString(b.Deps, r.Name)
+		case !util.SliceContains(b.Deps, r.Name):
+			b.Deps = append(b.Deps, r.Name)
+		}
+		return b
+	}
+
+	var walk func(n *depNode, parent *buildPackage)
+	walk = func(n *depNode, parent *buildPackage) {
+		for _, c := range n.Children {
+			switch {
+			case c.Type == "opt" || c.Cycle || c.Installed:
+				continue
+			case c.Missing:
+				missing = appendUnique(missing, c.Dep)
+				continue
+			case c.Record.Source != "AUR":
+				// makepkg installs dependencies from the repositories
+				continue
+			}
+			b := add(c.Record, false)
+			if b != parent {
+				parent.requires = appendUnique(parent.requires, b.Base)
+			}
+			walk(c, b)
+		}
+	}
+	for _, tree := range trees {
+		walk(tree, add(tree.Record, true))
+	}
+	if len(missing) > 0 {
+		return nil, errors.New("missing dependencies: " + strings.Join(missing, ", "))
+	}
+
+	// topological sort, dependencies first
+	order := []buildPackage{}
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if visiting[name] {
+			return errors.New("dependency cycle involving " + name)
+		}
+		visiting[name] = true
+		for _, r := range bases[name].requires {
+			if err := visit(r); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		order = append(order, *bases[name])
+		return nil
+	}
+	for _, name := range found {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// removes a value from a list
+func removeString(list []string, value string) []string {
+	result := []string{}
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// returns the package name of a package file, e.g. "foo-bar" for "foo-bar-1.0-1-x86_64.pkg.tar.zst"
+func builtPackageName(file string) string {
+	name := filepath.Base(file)
+	if i := strings.Index(name, ".pkg.tar"); i > 0 {
+		name = name[:i]
+	}
+	parts := strings.Split(name, "-")
+	if len(parts) < 4 {
+		return ""
+	}
+	return strings.Join(parts[:len(parts)-3], "-")
+}
+
+// returns the package files of a list that belong to some packages; each package needs to have a file
+func packageFilesFor(files []string, names []string) ([]string, error) {
+	matching := []string{}
+	for _, name := range names {
+		found := false
+		for _, file := range files {
+			if builtPackageName(file) == name {
+				matching = append(matching, file)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no package file found for %s", name)
+		}
+	}
+	return matching, nil
+}
+
+// escapeWriter escapes text for a tview.TextView line by line, so that tags are not split.
+// Tags can't be used when writing to it
+type escapeWriter struct {
+	mut sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// writes complete lines to the underlying writer
+func (e *escapeWriter) Write(p []byte) (int, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.buf = append(e.buf, p...)
+	if i := bytes.LastIndexByte(e.buf, '\n'); i >= 0 {
+		if _, err := io.WriteString(e.w, tview.Escape(string(e.buf[:i+1]))); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// writes what's left of an incomplete line to the underlying writer
+func (e *escapeWriter) Flush() error {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if len(e.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(e.w, tview.Escape(string(e.buf)))
+	e.buf = nil
+	return err
+}
+
+// returns our build directory ($XDG_CACHE_HOME/pacseek/build)
+func buildDir() (string, error) {
+	dir, err := util.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = path.Join(dir, buildDirectory)
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// runs a command and writes its output; a cancelled context interrupts the command
+func runBuildCommand(ctx context.Context, w io.Writer, dir string, name string, args ...string) error {
+	fmt.Fprintf(w, "\n==> %s %s\n", name, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = w
+	cmd.Stderr = w
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	err := cmd.Run()
+	// output without a trailing line break (e.g. a prompt) would not be shown otherwise
+	if f, ok := w.(interface{ Flush() error }); ok {
+		if ferr := f.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// runs a command and returns its output
+func buildCommandOutput(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// clones or pulls the git repository of a package base and writes the changes since our last build
+func fetchBuildSources(ctx context.Context, w io.Writer, dir string, b buildPackage) error {
+	repo := path.Join(dir, b.Base)
+	if _, err := os.Stat(path.Join(repo, ".git")); err == nil {
+		if err := runBuildCommand(ctx, w, repo, "git", "pull", "--ff-only"); err != nil {
+			return err
+		}
+	} else if err := runBuildCommand(ctx, w, dir, "git", "clone", fmt.Sprintf(aurGitURL, b.Base), b.Base); err != nil {
+		return err
+	}
+
+	// show all files if we've never built it before
+	since := gitEmptyTree
+	if _, err := buildCommandOutput(ctx, repo, "git", "rev-parse", "--verify", "-q", buildRef); err == nil {
+		since = buildRef
+	}
+	diff, err := buildCommandOutput(ctx, repo, "git", "diff", "--color=always", since, "HEAD")
+	if err != nil {
+		return err
+	}
+	if since == gitEmptyTree {
+		fmt.Fprintf(w, "\n==> %s has not been built before. Files:\n", b.Base)
+	} else {
+		fmt.Fprintf(w, "\n==> Changes of %s since the last build:\n", b.Base)
+	}
+	if diff == "" {
+		diff = "none\n"
+	}
+	_, err = w.Write([]byte(diff))
+	return err
+}
+
+// builds a package base with makepkg and installs the resulting packages
+func buildAndInstall(ctx context.Context, w io.Writer, dir string, b buildPackage, clean bool) error {
+	repo := path.Join(dir, b.Base)
+	if clean {
+		if err := runBuildCommand(ctx, w, repo, "git", "clean", "-ffdx"); err != nil {
+			return err
+		}
+	}
+
+	if err := runBuildCommand(ctx, w, repo, "makepkg", "--syncdeps", "--rmdeps", "--force", "--noconfirm"); err != nil {
+		return err
+	}
+
+	// pkgver() of VCS packages might have changed the version during the build, so we ask for the file names afterwards
+	list, err := buildCommandOutput(ctx, repo, "makepkg", "--packagelist")
+	if err != nil {
+		return err
+	}
+	files := strings.Fields(list)
+	deps, err := packageFilesFor(files, b.Deps)
+	if err != nil {
+		return err
+	}
+	targets, err := packageFilesFor(files, b.Targets)
+	if err != nil {
+		return err
+	}
+	if len(deps) > 0 {
+		if err := runBuildCommand(ctx, w, repo, "sudo", append([]string{"pacman", "-U", "--noconfirm", "--asdeps"}, deps...)...); err != nil {
+			return err
+		}
+	}
+	if len(targets) > 0 {
+		if err := runBuildCommand(ctx, w, repo, "sudo", append([]string{"pacman", "-U", "--noconfirm"}, targets...)...); err != nil {
+			return err
+		}
+	}
+
+	// remember what we've built and installed so that we can show the changes next time
+	if _, err = buildCommandOutput(ctx, repo, "git", "update-ref", buildRef, "HEAD"); err != nil {
+		return err
+	}
+	return savePkgbuildSnapshotsFrom(repo, b.Base)
+}
+
+// refreshes our sudo credentials until the context is done, so that makepkg / pacman don't ask for a password
+func keepSudoAlive(ctx context.Context) {
+	ticker := time.NewTicker(sudoKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			exec.Command("sudo", "-n", "-v").Run()
+		}
+	}
+}
+
+// builds AUR packages (including AUR dependencies) and installs them. done is called once the build has finished
+func (ps *UI) buildAur(pkgs []InfoRecord, done func()) {
+	names := []string{}
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	ps.textBuild.Clear()
+	ps.flexBuild.SetTitle(" [::b]" + ps.conf.Glyphs().Package + "Build - " + strings.Join(names, ", ") + " ")
+	ps.setBuildButtons(false, "Cancel")
+	ps.showBuild()
+	w := &escapeWriter{w: ps.buildWriter}
+
+	ps.jobs.run(jobBuild, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		order, err := ps.prepareBuild(ctx, pkgs, w)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				fmt.Fprintf(ps.textBuild, "\n[red::b]%s[-::-]\n", tview.Escape(err.Error()))
+				ps.setBuildButtons(false, "Close")
+				return
+			}
+			fmt.Fprintf(ps.textBuild, "\n[::b]Build order: %s. Review the changes above before building.[::-]\n", buildBases(order))
+			ps.setBuildButtons(true, "Build", "Cancel")
+			ps.formBuild.GetButton(0).SetSelectedFunc(func() {
+				ps.startBuild(order, w, done)
+			})
+		})
+	})
+}
+
+// resolves AUR dependencies and fetches the sources of all packages we need to build
+func (ps *UI) prepareBuild(ctx context.Context, pkgs []InfoRecord, w io.Writer) ([]buildPackage, error) {
+	fmt.Fprintln(w, "==> Resolving dependencies...")
+
+	// our records might be incomplete (e.g. for queued packages when caching is disabled)
+	if aur := ps.sourceFor("AUR"); aur != nil {
+		names := []string{}
+		for _, pkg := range pkgs {
+			names = append(names, pkg.Name)
+		}
+		fresh := aur.Info(ctx, names...).Results
+		for i, pkg := range pkgs {
+			for _, r := range fresh {
+				if r.Name == pkg.Name {
+					pkgs[i] = r
+				}
+			}
+		}
+	}
+
+	trees := []*depNode{}
+	for _, pkg := range pkgs {
+		trees = append(trees, ps.getDepTree(ctx, pkg, false))
+	}
+	order, err := aurBuildOrder(trees)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := buildDir()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range order {
+		if err := fetchBuildSources(ctx, w, dir, b); err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", b.Base, err)
+		}
+	}
+	return order, nil
+}
+
+// builds and installs packages in the background
+func (ps *UI) startBuild(order []buildPackage, w io.Writer, done func()) {
+	clean := ps.formBuild.GetFormItemCount() > 0 && ps.formBuild.GetFormItem(0).(*tview.Checkbox).IsChecked()
+
+	// authenticate upfront; we can't ask for a password while our UI is running
+	ps.runCommand(ps.shell, "-c", "sudo -v")
+	if err := exec.Command("sudo", "-n", "true").Run(); err != nil {
+		fmt.Fprintf(ps.textBuild, "\n[red::b]sudo authentication failed: %s[-::-]\n", tview.Escape(err.Error()))
+		ps.setBuildButtons(false, "Close")
+		return
+	}
+	ps.setBuildButtons(false, "Abort")
+
+	ps.jobs.run(jobBuild, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+		go keepSudoAlive(ctx)
+
+		dir, err := buildDir()
+		for _, b := range order {
+			if err != nil {
+				break
+			}
+			if err = buildAndInstall(ctx, w, dir, b, clean); err != nil {
+				err = fmt.Errorf("failed to build %s: %w", b.Base, err)
+			}
+		}
+		if ctx.Err() != nil {
+			err = errors.New("build has been aborted")
+		}
+
+		if rerr := ps.reinitPacmanDbs(); rerr != nil && err == nil {
+			err = rerr
+		}
+		ps.app.QueueUpdateDraw(func() {
+			ps.setBuildButtons(false, "Close")
+			ps.updateInstalledState()
+			if err != nil {
+				fmt.Fprintf(ps.textBuild, "\n[red::b]%s[-::-]\n", tview.Escape(err.Error()))
+				ps.displayMessage(err.Error(), true)
+			} else {
+				fmt.Fprintf(ps.textBuild, "\n[green::b]%s has been built and installed[-::-]\n", buildBases(order))
+				ps.displayMessage(buildBases(order)+" has been built and installed", false)
+			}
+			if done != nil {
+				done()
+			}
+		})
+	})
+}
+
+// returns the names of the package bases we build
+func buildBases(order []buildPackage) string {
+	names := []string{}
+	for _, b := range order {
+		names = append(names, b.Base)
+	}
+	return strings.Join(names, ", ")
+}
+
+// sets the buttons of our build view. Any button besides "Build" closes the view and cancels the build
+func (ps *UI) setBuildButtons(cleanOption bool, labels ...string) {
+	ps.formBuild.Clear(true)
+	if cleanOption {
+		ps.formBuild.AddCheckbox("Clean build: ", ps.conf.AurCleanBuild, nil)
+	}
+	for _, label := range labels {
+		ps.formBuild.AddButton(label, ps.closeBuild)
+	}
+	ps.formBuild.SetFocus(ps.formBuild.GetFormItemCount())
+	if ps.flexRight.GetItem(0) == ps.flexBuild {
+		ps.app.SetFocus(ps.formBuild)
+	}
+}
+
+// shows our build view
+func (ps *UI) showBuild() {
+	ps.flexRight.Clear().
+		AddItem(ps.flexBuild, 0, 1, true)
+	ps.app.SetFocus(ps.formBuild)
+}
+
+// cancels a running build and closes our build view
+func (ps *UI) closeBuild() {
+	ps.jobs.cancel(jobBuild)
+	ps.flexRight.Clear().
+		AddItem(ps.tableDetails, 0, 1, false)
+	ps.app.SetFocus(ps.tablePackages)
+}
+
+// scrolls the build output while the buttons have focus
+func (ps *UI) buildInputCapture(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn, tcell.KeyHome, tcell.KeyEnd:
+		ps.textBuild.InputHandler()(event, nil)
+		return nil
+	case tcell.KeyLeft:
+		if event.Modifiers() == tcell.ModCtrl {
+			ps.app.SetFocus(ps.tablePackages)
+			return nil
+		}
+	}
+	return event
+}
@@ -0,0 +1,238 @@
+package pacseek
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jguer/go-alpm/v2"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// packageColumn is a column that can be shown in the package list
+type packageColumn struct {
+	Name        string
+	Title       string // header text
+	Key         rune   // key for sorting by this column
+	Width       int    // maximum width; 0 means unlimited
+	Align       int
+	Transparent bool
+	text        func(ps *UI, p Package) string
+	color       func(ps *UI, p Package) tcell.Color
+	compare     func(a, b Package) int
+}
+
+// all columns that can be shown in the package list
+var packageColumns = []packageColumn{
+	{
+		Name:    "Name",
+		Title:   "Package",
+		Key:     'N',
+		text:    func(ps *UI, p Package) string { return p.Name },
+		compare: func(a, b Package) int { return cmp.Compare(a.Name, b.Name) },
+	},
+	{
+		Name: "Source",
+		Key:  'S',
+		text: func(ps *UI, p Package) string { return p.Source },
+		color: func(ps *UI, p Package) tcell.Color {
+			if p.Source == "AUR" {
+				return ps.conf.Colors().PackagelistSourceAUR
+			}
+			return ps.conf.Colors().PackagelistSourceRepository
+		},
+		compare: func(a, b Package) int { return cmp.Compare(a.Source, b.Source) },
+	},
+	{
+		Name:        "Installed",
+		Key:         'I',
+		Transparent: true,
+		text:        func(ps *UI, p Package) string { return ps.getInstalledStateText(p.IsInstalled) },
+		color:       func(ps *UI, p Package) tcell.Color { return ps.conf.Colors().DefaultBackground },
+		compare:     func(a, b Package) int { return compareBool(a.IsInstalled, b.IsInstalled) },
+	},
+	{
+		Name:    "Version",
+		Key:     'V',
+		Width:   20,
+		text:    func(ps *UI, p Package) string { return p.Version },
+		compare: func(a, b Package) int { return alpm.VerCmp(a.Version, b.Version) },
+	},
+	{
+		Name:    "Local version",
+		Key:     'L',
+		Width:   20,
+		text:    func(ps *UI, p Package) string { return p.LocalVersion },
+		compare: func(a, b Package) int { return alpm.VerCmp(a.LocalVersion, b.LocalVersion) },
+	},
+	{
+		Name:  "Votes",
+		Key:   'T',
+		Align: tview.AlignRight,
+		text: func(ps *UI, p Package) string {
+			if p.Source != "AUR" {
+				return ""
+			}
+			return strconv.Itoa(p.NumVotes)
+		},
+		compare: func(a, b Package) int { return cmp.Compare(a.NumVotes, b.NumVotes) },
+	},
+	{
+		Name:  "Popularity",
+		Key:   'P',
+		Align: tview.AlignRight,
+		text: func(ps *UI, p Package) string {
+			// packages from the repositories have max. popularity so that they are sorted first
+			if p.Popularity == math.MaxFloat64 {
+				return ""
+			}
+			return fmt.Sprintf("%.2f", p.Popularity)
+		},
+		compare: func(a, b Package) int { return cmp.Compare(a.Popularity, b.Popularity) },
+	},
+	{
+		Name: "Last modified",
+		Key:  'M',
+		text: func(ps *UI, p Package) string {
+			if p.LastModified == 0 {
+				return ""
+			}
+			return time.Unix(int64(p.LastModified), 0).UTC().Format("2006-01-02")
+		},
+		compare: func(a, b Package) int { return cmp.Compare(a.LastModified, b.LastModified) },
+	},
+	{
+		Name:  "Size",
+		Key:   'Z',
+		Align: tview.AlignRight,
+		text: func(ps *UI, p Package) string {
+			if p.Size == 0 {
+				return ""
+			}
+			return formatSize(p.Size)
+		},
+		compare: func(a, b Package) int { return cmp.Compare(a.Size, b.Size) },
+	},
+	{
+		Name: "Out-of-date",
+		Key:  'F',
+		text: func(ps *UI, p Package) string {
+			if p.OutOfDate == 0 {
+				return ""
+			}
+			return time.Unix(int64(p.OutOfDate), 0).UTC().Format("2006-01-02")
+		},
+		color:   func(ps *UI, p Package) tcell.Color { return tcell.ColorRed },
+		compare: func(a, b Package) int { return cmp.Compare(a.OutOfDate, b.OutOfDate) },
+	},
+	{
+		Name:  "Maintainer",
+		Key:   'A',
+		Width: 20,
+		text:  func(ps *UI, p Package) string { return p.Maintainer },
+		compare: func(a, b Package) int {
+			return cmp.Compare(strings.ToLower(a.Maintainer), strings.ToLower(b.Maintainer))
+		},
+	},
+}
+
+// returns the names of all available columns
+func packageColumnNames() []string {
+	names := []string{}
+	for _, c := range packageColumns {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// returns the columns for a list of column names.
+// The name column is always shown; unknown names are skipped and reported with an error
+func packageListColumns(names []string) ([]packageColumn, error) {
+	columns := []packageColumn{}
+	unknown := []string{}
+	added := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, c := range packageColumns {
+			if strings.EqualFold(c.Name, name) {
+				found = true
+				if !added[c.Name] {
+					columns = append(columns, c)
+					added[c.Name] = true
+				}
+				break
+			}
+		}
+		if !found && name != "" {
+			unknown = append(unknown, name)
+		}
+	}
+	if !added["Name"] {
+		columns = append([]packageColumn{packageColumns[0]}, columns...)
+	}
+
+	if len(unknown) > 0 {
+		return columns, fmt.Errorf("unknown column(s): %s (available: %s)", strings.Join(unknown, ", "), strings.Join(packageColumnNames(), ", "))
+	}
+	return columns, nil
+}
+
+// returns the column for a sorting key
+func packageColumnByKey(key rune) (packageColumn, bool) {
+	for _, c := range packageColumns {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return packageColumn{}, false
+}
+
+// returns the position of a column in our package list or -1 if it is not shown
+func (ps *UI) columnIndex(name string) int {
+	for i, c := range ps.columns {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// sorts packages by a column; packages with equal values are sorted by name
+func sortPackagesBy(packages []Package, col packageColumn, descending bool) {
+	sort.SliceStable(packages, func(i, j int) bool {
+		c := col.compare(packages[i], packages[j])
+		if descending {
+			c = -c
+		}
+		if c == 0 {
+			return packages[i].Name < packages[j].Name
+		}
+		return c < 0
+	})
+}
+
+// compares two booleans; false comes first
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// returns the package shown in a row of our package list
+func (ps *UI) packageAt(row int) (Package, bool) {
+	if row < 1 || row >= ps.tablePackages.GetRowCount() {
+		return Package{}, false
+	}
+	pkg, ok := ps.tablePackages.GetCell(row, 0).Reference.(Package)
+	return pkg, ok
+}
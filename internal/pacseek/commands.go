@@ -8,8 +8,8 @@ import (
 	"strings"
 )
 
-// installs or removes a package
-func (ps *UI) installPackage(pkg InfoRecord, installed bool) {
+// installs or removes a package. done is called after our command has been run
+func (ps *UI) installPackage(pkg InfoRecord, installed bool, done func()) {
 	// set command based on source and install status
 	command := ps.conf.InstallCommand
 	if installed {
@@ -18,33 +18,82 @@ func (ps *UI) installPackage(pkg InfoRecord, installed bool) {
 		command = ps.conf.AurInstallCommand
 	}
 
-	// if our command contains {pkg}, replace it with the package name, otherwise concat it
-	if strings.Contains(command, "{pkg}") {
-		command = strings.Replace(command, "{pkg}", pkg.Name, -1)
+	command = composeCommand(command, pkg)
+
+	// Here I'm assuming -c is the argument for passing a command to the shell
+	// This might not be valid for all of em though.
+	args := []string{"-c", command}
+
+	install, remove := []InfoRecord{pkg}, []InfoRecord{}
+	if installed {
+		install, remove = remove, install
+	}
+	ps.previewTransaction(install, remove, func() {
+		if !installed && pkg.Source == "AUR" && ps.conf.AurBuildInternally {
+			ps.buildAur([]InfoRecord{pkg}, done)
+			return
+		}
+		ps.runCommand(ps.shell, args...)
+
+		// update package install status
+		ps.updateInstalledState()
+		if done != nil {
+			done()
+		}
+	})
+}
+
+// replaces placeholders in an install / uninstall command with package information.
+// When multiple packages are given, they are being processed in one transaction,
+// unless the command contains placeholders that only apply to a single package
+func composeCommand(command string, pkgs ...InfoRecord) string {
+	if len(pkgs) == 0 {
+		return command
+	}
+
+	// one command per package if we got package specific placeholders
+	if len(pkgs) > 1 {
+		for _, placeholder := range []string{"{repo}", "{giturl}", "{pkgbase}"} {
+			if strings.Contains(command, placeholder) {
+				commands := []string{}
+				for _, pkg := range pkgs {
+					commands = append(commands, composeCommand(command, pkg))
+				}
+				return strings.Join(commands, " && ")
+			}
+		}
+	}
+
+	names := []string{}
+	optdepends := []string{}
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+		optdepends = append(optdepends, pkg.OptDepends...)
+	}
+	joined := strings.Join(names, " ")
+
+	// if our command contains {pkgs} or {pkg}, replace it with the package name(s), otherwise concat it
+	if strings.Contains(command, "{pkgs}") {
+		command = strings.Replace(command, "{pkgs}", joined, -1)
+	} else if strings.Contains(command, "{pkg}") {
+		command = strings.Replace(command, "{pkg}", joined, -1)
 	} else {
-		command += " " + pkg.Name
+		command += " " + joined
 	}
 
 	// replace optdepends
-	command = strings.Replace(command, "{optdepends}", strings.Join(pkg.OptDepends, " "), -1)
+	command = strings.Replace(command, "{optdepends}", strings.Join(optdepends, " "), -1)
 
 	// replace repo
-	command = strings.Replace(command, "{repo}", strings.ToLower(pkg.Source), -1)
+	command = strings.Replace(command, "{repo}", strings.ToLower(pkgs[0].Source), -1)
 
 	// replace {giturl} with AUR url if defined
-	if pkg.Source == "AUR" {
-		command = strings.Replace(command, "{giturl}", "https://aur.archlinux.org/"+pkg.PackageBase+".git", -1)
-		command = strings.Replace(command, "{pkgbase}", pkg.PackageBase, -1)
+	if pkgs[0].Source == "AUR" {
+		command = strings.Replace(command, "{giturl}", "https://aur.archlinux.org/"+pkgs[0].PackageBase+".git", -1)
+		command = strings.Replace(command, "{pkgbase}", pkgs[0].PackageBase, -1)
 	}
 
-	// Here I'm assuming -c is the argument for passing a command to the shell
-	// This might not be valid for all of em though.
-	args := []string{"-c", command}
-
-	ps.runCommand(ps.shell, args...)
-
-	// update package install status
-	ps.updateInstalledState()
+	return command
 }
 
 // installs or removes a package
@@ -53,9 +102,9 @@ func (ps *UI) installSelectedPackage() {
 		return
 	}
 	row, _ := ps.tablePackages.GetSelection()
-	installed := ps.tablePackages.GetCell(row, 2).Reference == true
+	pkg, _ := ps.packageAt(row)
 
-	ps.installPackage(*ps.selectedPackage, installed)
+	ps.installPackage(*ps.selectedPackage, pkg.IsInstalled, nil)
 }
 
 // issues "Update command"
@@ -102,6 +151,8 @@ func (ps *UI) runCommand(command string, args ...string) {
 
 // re-initializes the alpm handler
 func (ps *UI) reinitPacmanDbs() error {
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
 	err := ps.alpmHandle.Release()
 	if err != nil {
 		return err
@@ -129,3 +180,31 @@ func handleSigint(cmd *exec.Cmd) chan bool {
 	}()
 	return quit
 }
+
+// switches the install reason of the selected package between explicit and dependency
+func (ps *UI) toggleInstallReason() {
+	if ps.selectedPackage == nil {
+		return
+	}
+	pkg := *ps.selectedPackage
+	if pkg.InstallReason == "" {
+		ps.displayMessage(pkg.Name+" is not installed", true)
+		return
+	}
+
+	ps.runCommand(ps.shell, "-c", installReasonCommand(ps.conf.InstallReasonCommand, pkg))
+
+	// show updated package info
+	ps.cacheInfo.Delete(pkg.Name + "-" + pkg.Source)
+	row, col := ps.tablePackages.GetSelection()
+	ps.displayPackageInfo(row, col)
+}
+
+// composes the command to switch the install reason of a package
+func installReasonCommand(command string, pkg InfoRecord) string {
+	reason := "--asdeps"
+	if pkg.InstallReason == "Dependency" {
+		reason = "--asexplicit"
+	}
+	return composeCommand(strings.Replace(command, "{reason}", reason, -1), pkg)
+}
@@ -0,0 +1,181 @@
+package pacseek
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// matches the header of a comment on the AUR package page: id, author and date
+var commentHeaderRegex = regexp.MustCompile(`(?s)^(\d+)"[^>]*>\s*(.*?)\s+commented on\s+<a href="#comment-\d+" class="date">([^<]+)</a>`)
+
+// matches the content of a comment
+var (
+	commentContentRegex      = regexp.MustCompile(`(?s)class="article-content">\s*<div>(.*?)</div>\s*</div>`)
+	commentContentPlainRegex = regexp.MustCompile(`(?s)class="article-content">(.*?)</div>`)
+)
+
+var (
+	htmlTagRegex       = regexp.MustCompile(`<[^>]*>`)
+	htmlLineBreakRegex = regexp.MustCompile(`<br\s*/?>\n?`)
+	htmlBlockEndRegex  = regexp.MustCompile(`</(p|li|pre|h[1-6]|blockquote)>`)
+)
+
+// aurComment is a comment of an AUR package base
+type aurComment struct {
+	ID     string
+	Author string
+	Date   time.Time
+	Text   string
+	Pinned bool
+}
+
+// parses the comments of an AUR package page; pinned comments come first
+func parseAurComments(page string) []aurComment {
+	pinnedStart := strings.Index(page, "Pinned Comments")
+	latestStart := strings.Index(page, "Latest Comments")
+
+	comments := []aurComment{}
+	seen := map[string]bool{}
+	parts := strings.Split(page, `<h4 id="comment-`)
+	offset := len(parts[0])
+	for _, part := range parts[1:] {
+		pos := offset
+		offset += len(`<h4 id="comment-`) + len(part)
+
+		m := commentHeaderRegex.FindStringSubmatch(part)
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		content := commentContentRegex.FindStringSubmatch(part)
+		if content == nil {
+			content = commentContentPlainRegex.FindStringSubmatch(part)
+		}
+		text := ""
+		if content != nil {
+			text = htmlToText(content[1])
+		}
+		date, _ := time.Parse("2006-01-02 15:04 (MST)", strings.TrimSpace(m[3]))
+		comments = append(comments, aurComment{
+			ID:     m[1],
+			Author: htmlToText(m[2]),
+			Date:   date,
+			Text:   text,
+			Pinned: pinnedStart != -1 && pinnedStart < pos && (latestStart == -1 || pos < latestStart),
+		})
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Pinned && !comments[j].Pinned
+	})
+	return comments
+}
+
+// converts a HTML snippet to plain text
+func htmlToText(s string) string {
+	s = htmlLineBreakRegex.ReplaceAllString(s, "\n")
+	s = htmlBlockEndRegex.ReplaceAllString(s, "\n\n")
+	s = html.UnescapeString(htmlTagRegex.ReplaceAllString(s, ""))
+
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// checks if a comment has been written after the installed version of a package was built
+func isNewComment(c aurComment, pkg InfoRecord) bool {
+	return pkg.LocalBuildDate != 0 && c.Date.After(time.Unix(int64(pkg.LocalBuildDate), 0))
+}
+
+// returns the comments of an AUR package base, from cache if possible
+func (ps *UI) getAurComments(ctx context.Context, pkg InfoRecord) ([]aurComment, error) {
+	if cached, found := ps.cacheComments.Get(pkg.PackageBase); found {
+		return cached.([]aurComment), nil
+	}
+	// the package page shows the comments of its base
+	page, err := getPkgbuildContent(ctx, fmt.Sprintf(UrlAurComments, pkg.Name))
+	if err != nil {
+		return nil, err
+	}
+	comments := parseAurComments(page)
+	if !ps.conf.DisableCache {
+		ps.cacheComments.Set(pkg.PackageBase, comments, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+	}
+	return comments, nil
+}
+
+// shows the comments of the selected AUR package
+func (ps *UI) displayComments() {
+	if ps.selectedPackage == nil {
+		return
+	}
+	pkg := *ps.selectedPackage
+
+	ps.textComments.Clear().
+		SetTitle(" [::b]Loading comments... ")
+	ps.flexRight.Clear().
+		AddItem(ps.textComments, 0, 1, true)
+	ps.app.SetFocus(ps.textComments)
+
+	if pkg.Source != "AUR" {
+		ps.textComments.SetTitle(" [::b]Comments - " + pkg.Name + " ")
+		ps.textComments.SetText("Comments are only available for AUR packages")
+		return
+	}
+
+	ps.jobs.run(jobComments, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		comments, err := ps.getAurComments(ctx, pkg)
+		// the selected record might come from our cache, we need the build date of the installed package
+		pkg := ps.withInstallState(pkg)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ps.textComments.SetTitle(" [::b]Error loading comments - " + pkg.Name + " ")
+				ps.textComments.SetText(err.Error())
+				return
+			}
+			ps.drawComments(comments, pkg)
+		})
+	})
+}
+
+// draws the comments of a package; the ones written after the installed version was built are highlighted
+func (ps *UI) drawComments(comments []aurComment, pkg InfoRecord) {
+	lines := []string{}
+	newer := 0
+	for _, c := range comments {
+		header := "[::b]" + tview.Escape(c.Author) + "[::-] - " + c.Date.Format("2006-01-02 15:04 (MST)")
+		if c.Pinned {
+			header = "[green]pinned[-] " + header
+		}
+		if isNewComment(c, pkg) {
+			newer++
+			header = "[yellow]" + header + " - new since your build[-]"
+		}
+		lines = append(lines, header, tview.Escape(c.Text), "")
+	}
+	if len(comments) == 0 {
+		lines = append(lines, "No comments")
+	}
+
+	ps.textComments.SetTitle(fmt.Sprintf(" [::b]Comments - %s (%d comments, %d new) ", pkg.Name, len(comments), newer))
+	ps.textComments.SetText(strings.Join(lines, "\n")).
+		ScrollToBeginning()
+}
@@ -1,5 +1,11 @@
 package pacseek
 
+import (
+	"context"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
 // SearchResults is a data structure that is being sent back from the RPC service
 type SearchResults struct {
 	Error       string       `json:"error,omitempty"`
@@ -22,6 +28,8 @@ type InfoRecord struct {
 	LastModified      int      `json:"LastModified"`
 	License           []string `json:"License"`
 	Maintainer        string   `json:"Maintainer"`
+	CoMaintainers     []string `json:"CoMaintainers,omitempty"`
+	Submitter         string   `json:"Submitter,omitempty"`
 	MakeDepends       []string `json:"MakeDepends,omitempty"`
 	Name              string   `json:"Name"`
 	NumVotes          int      `json:"NumVotes"`
@@ -37,6 +45,12 @@ type InfoRecord struct {
 	URLPath           string   `json:"URLPath"`
 	Version           string   `json:"Version"`
 	LocalVersion      string
+	InstallReason     string `json:"InstallReason,omitempty"`
+	InstallDate       int    `json:"InstallDate,omitempty"`
+	LocalBuildDate    int    `json:"LocalBuildDate,omitempty"` // build date of the local version
+	DownloadSize      int64  `json:"DownloadSize,omitempty"`
+	InstalledSize     int64  `json:"InstalledSize,omitempty"`
+	LocalSize         int64  `json:"LocalSize,omitempty"` // installed size of the local version
 	Source            string `json:"Source"`
 	Architecture      string `json:"Architecture"`
 	IsIgnored         bool
@@ -57,20 +71,102 @@ type Package struct {
 	IsInstalled  bool
 	LastModified int
 	Popularity   float64
+	Size         int64 // installed size; not available for AUR packages
+	Version      string
+	LocalVersion string
+	NumVotes     int
+	OutOfDate    int
+	Maintainer   string
+
+	MatchingFiles []string // only set when searching by files
 }
 
 // get package information
-func (ps *UI) getInfo(source string, pkgs ...string) SearchResults {
-	sr := SearchResults{}
-	if source == "AUR" || source == "all" {
-		sr = infoAur(ps.conf.AurRpcUrl, ps.conf.AurTimeout, pkgs...)
-		if source == "all" {
-			sr.Results = append(sr.Results, infoPacman(ps.alpmHandle, ps.conf.ComputeRequiredBy, pkgs...).Results...)
+func (ps *UI) getInfo(ctx context.Context, source string, pkgs ...string) SearchResults {
+	sr := SearchResults{
+		Results: []InfoRecord{},
+	}
+	for _, s := range ps.sources {
+		if source != "all" && !s.Handles(source) {
+			continue
+		}
+		r := s.Info(ctx, pkgs...)
+		if r.Error != "" {
+			sr.Error = r.Error
+		}
+		sr.Results = append(sr.Results, r.Results...)
+		if source != "all" {
+			break
 		}
-	} else {
-		sr = infoPacman(ps.alpmHandle, ps.conf.ComputeRequiredBy, pkgs...)
 	}
 
+	ps.locker.Lock()
+	addInstallState(ps.alpmHandle, sr.Results...)
 	addLocalSatisfiers(ps.alpmHandle, sr.Results...)
+	ps.locker.Unlock()
 	return sr
 }
+
+// returns a package record with the current state of the installed package; cached records might lack or predate it
+func (ps *UI) withInstallState(pkg InfoRecord) InfoRecord {
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+	records := []InfoRecord{pkg}
+	addInstallState(ps.alpmHandle, records...)
+	return records[0]
+}
+
+// syncs a temporary DB and returns the packages that can be upgraded from the repositories and the AUR
+func (ps *UI) findUpgrades(ctx context.Context) ([]InfoRecord, error) {
+	h, err := syncToTempDB(ps.conf.PacmanConfigPath, ps.filterRepos)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Release()
+
+	up, nf := getUpgradable(h, ps.conf.ComputeRequiredBy)
+	var aurPkgs SearchResults
+	if aur := ps.sourceFor("AUR"); aur != nil {
+		aurPkgs = aur.Info(ctx, nf...)
+	} else {
+		aurPkgs = infoAur(ctx, ps.conf.AurRpcUrl, ps.conf.AurTimeout, nf...)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	for _, aurPkg := range aurPkgs.Results {
+		for i := 0; i < len(up); i++ {
+			if up[i].Source == "local" && up[i].Name == aurPkg.Name {
+				if alpm.VerCmp(aurPkg.Version, up[i].LocalVersion) > 0 {
+					up[i].Description = aurPkg.Description
+					up[i].Version = aurPkg.Version
+					up[i].Source = "AUR"
+				}
+			}
+		}
+	}
+	foundUp := []InfoRecord{}
+	for _, pkg := range up {
+		if pkg.Version != pkg.LocalVersion {
+			foundUp = append(foundUp, pkg)
+		}
+	}
+	return foundUp, nil
+}
+
+// returns the total download size and installed size difference of a sysupgrade.
+// Sizes of AUR packages are unknown, so we only report if there are any
+func upgradeSizes(up []InfoRecord) (download int64, delta int64, aur bool) {
+	for _, pkg := range up {
+		if pkg.IsIgnored {
+			continue
+		}
+		if pkg.Source == "AUR" {
+			aur = true
+			continue
+		}
+		download += pkg.DownloadSize
+		delta += pkg.InstalledSize - pkg.LocalSize
+	}
+	return download, delta, aur
+}
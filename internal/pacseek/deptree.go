@@ -0,0 +1,422 @@
+package pacseek
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/rivo/tview"
+)
+
+// depNode is a package in a dependency tree
+type depNode struct {
+	Dep       string // dependency string, e.g. "java-runtime>=17"; empty for the root node
+	Type      string // dep, make or opt
+	Name      string
+	Record    InfoRecord
+	Installed bool
+	Missing   bool     // nothing satisfies the dependency
+	Cycle     bool     // the package is one of its own (indirect) dependencies
+	Repeated  bool     // the package is expanded elsewhere in the tree
+	Conflicts []string // installed packages it conflicts with
+	Children  []*depNode
+
+	parent *depNode
+}
+
+// depTarget is a package satisfying a dependency
+type depTarget struct {
+	Record    InfoRecord
+	Installed bool
+	Conflicts []string
+}
+
+// depResolver looks up the packages satisfying a list of dependencies.
+// Dependencies that can't be satisfied are left out
+type depResolver func(ctx context.Context, deps []string) map[string]depTarget
+
+// depSummary describes what installing the root package of a tree would pull in
+type depSummary struct {
+	New          []InfoRecord // packages that would be installed (without optional ones)
+	Aur          int          // number of new packages from the AUR
+	Optional     int          // number of optional packages that are not installed
+	Missing      []string     // required dependencies that can't be satisfied
+	Conflicts    []string     // conflicts of packages that would be installed (without optional ones)
+	Cycles       int
+	DownloadSize int64
+}
+
+// returns the package name of a dependency string like "glibc>=2.38" or "python: for scripts"
+func depName(dep string) string {
+	if i := strings.IndexAny(dep, "<>=:"); i != -1 {
+		dep = dep[:i]
+	}
+	return strings.TrimSpace(dep)
+}
+
+// resolves the dependencies of a package recursively.
+// With withOptional set, optional dependencies of the root package are resolved as well.
+// Make dependencies are only resolved for AUR packages we'd need to build
+func buildDepTree(ctx context.Context, root InfoRecord, resolve depResolver, withOptional bool) *depNode {
+	rootNode := &depNode{
+		Name:      root.Name,
+		Record:    root,
+		Installed: root.LocalVersion != "",
+	}
+	expanded := map[string]bool{root.Name: true}
+
+	// required dependencies first, so that they are expanded in the tree rather than below an optional one
+	expandDeps(ctx, []*depNode{rootNode}, resolve, expanded)
+	if !withOptional {
+		return rootNode
+	}
+	optional := addDeps(ctx, rootNode, []depList{{"opt", root.OptDepends}}, resolve, expanded)
+	expandDeps(ctx, optional, resolve, expanded)
+
+	return rootNode
+}
+
+// depList is a list of dependencies of a certain type
+type depList struct {
+	depType string
+	deps    []string
+}
+
+// resolves the required dependencies of packages level by level, so that we can resolve them in batches
+func expandDeps(ctx context.Context, level []*depNode, resolve depResolver, expanded map[string]bool) {
+	for len(level) > 0 && ctx.Err() == nil {
+		next := []*depNode{}
+		pending := map[*depNode][]depList{}
+		deps := []string{}
+		for _, n := range level {
+			lists := []depList{{"dep", n.Record.Depends}}
+			if n.Record.Source == "AUR" && (!n.Installed || n.parent == nil) {
+				lists = append(lists, depList{"make", n.Record.MakeDepends})
+			}
+			pending[n] = lists
+			for _, l := range lists {
+				deps = append(deps, l.deps...)
+			}
+		}
+		targets := resolve(ctx, deps)
+		for _, n := range level {
+			next = append(next, attachDeps(n, pending[n], targets, expanded)...)
+		}
+		level = next
+	}
+}
+
+// resolves and adds dependencies to a node and returns the children that need to be expanded
+func addDeps(ctx context.Context, n *depNode, lists []depList, resolve depResolver, expanded map[string]bool) []*depNode {
+	deps := []string{}
+	for _, l := range lists {
+		deps = append(deps, l.deps...)
+	}
+	if len(deps) == 0 || ctx.Err() != nil {
+		return nil
+	}
+	return attachDeps(n, lists, resolve(ctx, deps), expanded)
+}
+
+// adds the resolved dependencies to a node and returns the children that need to be expanded
+func attachDeps(n *depNode, lists []depList, targets map[string]depTarget, expanded map[string]bool) []*depNode {
+	toExpand := []*depNode{}
+	for _, l := range lists {
+		for _, dep := range l.deps {
+			child := &depNode{
+				Dep:    dep,
+				Type:   l.depType,
+				Name:   depName(dep),
+				parent: n,
+			}
+			n.Children = append(n.Children, child)
+
+			t, ok := targets[dep]
+			if !ok {
+				child.Missing = true
+				continue
+			}
+			child.Name = t.Record.Name
+			child.Record = t.Record
+			child.Installed = t.Installed
+			child.Conflicts = t.Conflicts
+
+			switch {
+			case child.inPath(child.Name):
+				child.Cycle = true
+			case expanded[child.Name]:
+				child.Repeated = true
+			default:
+				expanded[child.Name] = true
+				toExpand = append(toExpand, child)
+			}
+		}
+	}
+	return toExpand
+}
+
+// checks if a package is one of the ancestors of a node
+func (n *depNode) inPath(name string) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sums up what installing the root package would pull in
+func (n *depNode) summary() depSummary {
+	s := depSummary{}
+	seen := map[string]bool{}
+	optional := map[string]bool{}
+
+	var walk func(node *depNode, isOptional bool)
+	walk = func(node *depNode, isOptional bool) {
+		isOptional = isOptional || node.Type == "opt"
+		switch {
+		case node.Missing && !isOptional && !util.SliceContains(s.Missing, node.Dep):
+			s.Missing = append(s.Missing, node.Dep)
+		case node.Cycle:
+			s.Cycles++
+		}
+		if !node.Repeated && !isOptional {
+			for _, c := range node.Conflicts {
+				s.Conflicts = append(s.Conflicts, node.Name+" <> "+c)
+			}
+		}
+		if !node.Missing && !node.Installed && node.parent != nil {
+			if isOptional {
+				optional[node.Name] = true
+			} else if !seen[node.Name] {
+				seen[node.Name] = true
+				s.New = append(s.New, node.Record)
+				s.DownloadSize += node.Record.DownloadSize
+				if node.Record.Source == "AUR" {
+					s.Aur++
+				}
+			}
+		}
+		for _, c := range node.Children {
+			walk(c, isOptional)
+		}
+	}
+	walk(n, false)
+
+	for name := range optional {
+		if !seen[name] {
+			s.Optional++
+		}
+	}
+	return s
+}
+
+// resolves dependencies with the pacman DB's and falls back to the AUR
+func (ps *UI) resolveDeps(ctx context.Context, deps []string) map[string]depTarget {
+	ps.locker.Lock()
+	targets, unresolved := resolveRepoDeps(ps.alpmHandle, deps)
+	ps.locker.Unlock()
+
+	aur := ps.sourceFor("AUR")
+	if len(unresolved) == 0 || aur == nil {
+		return targets
+	}
+
+	names := []string{}
+	for _, dep := range unresolved {
+		names = append(names, depName(dep))
+	}
+	records := map[string]InfoRecord{}
+	for _, r := range aur.Info(ctx, names...).Results {
+		records[r.Name] = r
+	}
+
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+	local, err := ps.alpmHandle.LocalDB()
+	for _, dep := range unresolved {
+		r, ok := records[depName(dep)]
+		if !ok {
+			continue
+		}
+		t := depTarget{Record: r}
+		if err == nil {
+			t.Conflicts = localConflicts(local, r)
+		}
+		targets[dep] = t
+	}
+	return targets
+}
+
+// resolves dependencies with the local DB (installed packages) and the sync DB's.
+// The second return value contains the dependencies that could not be satisfied
+func resolveRepoDeps(h *alpm.Handle, deps []string) (map[string]depTarget, []string) {
+	targets := map[string]depTarget{}
+	unresolved := []string{}
+	if h == nil {
+		return targets, deps
+	}
+	local, err := h.LocalDB()
+	if err != nil {
+		return targets, deps
+	}
+	dbs, err := h.SyncDBs()
+	if err != nil {
+		return targets, deps
+	}
+
+	for _, dep := range deps {
+		if _, ok := targets[dep]; ok {
+			continue
+		}
+		if lpkg, _ := local.PkgCache().FindSatisfier(dep); lpkg != nil {
+			source := "local"
+			for _, db := range dbs.Slice() {
+				if db.Pkg(lpkg.Name()) != nil {
+					source = db.Name()
+					break
+				}
+			}
+			r := alpmInfoRecord(lpkg, source)
+			setInstallState(&r, lpkg)
+			targets[dep] = depTarget{Record: r, Installed: true}
+			continue
+		}
+		if spkg, _ := dbs.FindSatisfier(dep); spkg != nil {
+			r := alpmInfoRecord(spkg, spkg.DB().Name())
+			targets[dep] = depTarget{Record: r, Conflicts: localConflicts(local, r)}
+			continue
+		}
+		unresolved = append(unresolved, dep)
+	}
+	return targets, unresolved
+}
+
+// returns installed packages a package conflicts with
+func localConflicts(local alpm.IDB, r InfoRecord) []string {
+	conflicts := []string{}
+	for _, c := range r.Conflicts {
+		if lpkg, _ := local.PkgCache().FindSatisfier(c); lpkg != nil && lpkg.Name() != r.Name {
+			conflicts = append(conflicts, lpkg.Name())
+		}
+	}
+	return conflicts
+}
+
+// resolves the dependency tree of a package; optional dependencies are only resolved with withOptional set
+func (ps *UI) getDepTree(ctx context.Context, pkg InfoRecord, withOptional bool) *depNode {
+	pkg = ps.withInstallState(pkg)
+	root := buildDepTree(ctx, pkg, ps.resolveDeps, withOptional)
+	if !root.Installed {
+		ps.locker.Lock()
+		if local, err := ps.alpmHandle.LocalDB(); err == nil {
+			root.Conflicts = localConflicts(local, pkg)
+		}
+		ps.locker.Unlock()
+	}
+	return root
+}
+
+// displays the dependency tree of the selected package
+func (ps *UI) displayDepTree() {
+	if ps.selectedPackage == nil {
+		return
+	}
+	pkg := *ps.selectedPackage
+
+	loading := tview.NewTreeNode("Resolving dependencies...")
+	ps.reverseDeps = false
+	ps.treeDeps.SetRoot(loading).
+		SetCurrentNode(loading).
+		SetTitle(" [::b]" + ps.conf.Glyphs().Package + "Dependencies - " + pkg.Name + " ")
+	ps.flexRight.Clear().
+		AddItem(ps.treeDeps, 0, 1, true)
+	ps.app.SetFocus(ps.treeDeps)
+
+	ps.jobs.run(jobDeps, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		tree := ps.getDepTree(ctx, pkg, true)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			ps.drawDepTree(tree)
+		})
+	})
+}
+
+// draws a dependency tree
+func (ps *UI) drawDepTree(tree *depNode) {
+	var add func(n *depNode) *tview.TreeNode
+	add = func(n *depNode) *tview.TreeNode {
+		node := tview.NewTreeNode(ps.depNodeText(n)).
+			SetReference(n).
+			// installed packages are collapsed, we're mostly interested in what's missing
+			SetExpanded(n.parent == nil || !n.Installed)
+		for _, c := range n.Children {
+			node.AddChild(add(c))
+		}
+		return node
+	}
+	root := add(tree)
+	ps.treeDeps.SetRoot(root).
+		SetCurrentNode(root)
+
+	s := tree.summary()
+	title := fmt.Sprintf("%d new (%d AUR", len(s.New), s.Aur)
+	if s.DownloadSize > 0 {
+		title += ", " + formatSize(s.DownloadSize)
+	}
+	title += fmt.Sprintf("), %d optional", s.Optional)
+	if len(s.Missing) > 0 {
+		title += fmt.Sprintf(", [red]%d missing[-]", len(s.Missing))
+	}
+	if len(s.Conflicts) > 0 {
+		title += fmt.Sprintf(", [red]%d conflicts[-]", len(s.Conflicts))
+	}
+	if s.Cycles > 0 {
+		title += fmt.Sprintf(", [yellow]%d cycles[-]", s.Cycles)
+	}
+	ps.treeDeps.SetTitle(" [::b]" + ps.conf.Glyphs().Package + "Dependencies - " + tree.Name + "[::-] - " + title + " ")
+}
+
+// composes the text of a tree node
+func (ps *UI) depNodeText(n *depNode) string {
+	text := ps.getInstalledStateText(n.Installed) + " [::b]" + tview.Escape(n.Name) + "[::-]"
+	if n.Dep != "" && depName(n.Dep) != n.Name {
+		text += " (" + tview.Escape(n.Dep) + ")"
+	}
+	if n.Record.Source != "" {
+		text += " " + tview.Escape("["+n.Record.Source+"]")
+	}
+
+	notes := []string{}
+	switch n.Type {
+	case "make":
+		notes = append(notes, "make")
+	case "opt":
+		notes = append(notes, "optional")
+	}
+	switch {
+	case n.Missing:
+		notes = append(notes, "[red]not found[-]")
+	case n.Cycle:
+		notes = append(notes, "[yellow]cycle[-]")
+	case n.Repeated:
+		notes = append(notes, "expanded elsewhere")
+	}
+	if !n.Installed && !n.Missing && n.parent != nil {
+		notes = append(notes, "[green]new[-]")
+	}
+	for _, c := range n.Conflicts {
+		notes = append(notes, "[red]conflicts with "+tview.Escape(c)+"[-]")
+	}
+	if len(notes) > 0 {
+		text += " - " + strings.Join(notes, ", ")
+	}
+	return text
+}
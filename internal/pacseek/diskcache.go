@@ -0,0 +1,194 @@
+package pacseek
+
+import (
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/patrickmn/go-cache"
+)
+
+// version of our on-disk cache format.
+// Increase it whenever InfoRecord or Package change, so that old entries are being discarded
+const diskCacheVersion = 3
+
+// names of our cache files
+const (
+	diskCacheInfo     = "info"
+	diskCacheSearch   = "search"
+	diskCachePkgbuild = "pkgbuild"
+	diskCacheComments = "comments"
+)
+
+// diskCacheFile is the data structure that is stored on disk
+type diskCacheFile struct {
+	Version int
+	Items   map[string]cache.Item
+}
+
+func init() {
+	// types stored as interface values need to be registered for gob
+	gob.Register(InfoRecord{})
+	gob.Register([]InfoRecord{})
+	gob.Register([]Package{})
+	gob.Register([]gitCommit{})
+	gob.Register([]aurComment{})
+}
+
+// returns the path to a cache file
+func diskCachePath(name string) (string, error) {
+	dir, err := util.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, name+".cache"), nil
+}
+
+// reads items from a cache file; expired entries and entries of an old format version are discarded
+func loadDiskCache(name string, maxExpiry time.Duration) (map[string]cache.Item, error) {
+	items := map[string]cache.Item{}
+	file, err := diskCachePath(name)
+	if err != nil {
+		return items, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return items, nil
+	}
+	if err != nil {
+		return items, err
+	}
+	defer f.Close()
+
+	var content diskCacheFile
+	if err = gob.NewDecoder(f).Decode(&content); err != nil || content.Version != diskCacheVersion {
+		// unreadable or outdated, start over
+		os.Remove(file)
+		return items, nil
+	}
+
+	now := time.Now()
+	maxExp := now.Add(maxExpiry).UnixNano()
+	for k, item := range content.Items {
+		// never restore entries depending on the current system state
+		if strings.HasPrefix(k, "#") {
+			continue
+		}
+		if item.Expiration > 0 && item.Expiration < now.UnixNano() {
+			continue
+		}
+		if item.Expiration == 0 || item.Expiration > maxExp {
+			item.Expiration = maxExp
+		}
+		items[k] = item
+	}
+	return items, nil
+}
+
+// writes items to a cache file
+func saveDiskCache(name string, items map[string]cache.Item) error {
+	file, err := diskCachePath(name)
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(f).Encode(diskCacheFile{
+		Version: diskCacheVersion,
+		Items:   items,
+	})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// removes all cache files
+func wipeDiskCache() error {
+	for _, name := range []string{diskCacheInfo, diskCacheSearch, diskCachePkgbuild, diskCacheComments} {
+		file, err := diskCachePath(name)
+		if err != nil {
+			return err
+		}
+		if err = os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the in-memory caches by their file name
+func (ps *UI) cacheLayers() map[string]*cache.Cache {
+	return map[string]*cache.Cache{
+		diskCacheInfo:     ps.cacheInfo,
+		diskCacheSearch:   ps.cacheSearch,
+		diskCachePkgbuild: ps.cachePkgbuild,
+		diskCacheComments: ps.cacheComments,
+	}
+}
+
+// populates our in-memory caches with the data stored on disk
+func (ps *UI) loadCaches() error {
+	if ps.conf.DisableCache || !ps.conf.EnableDiskCache {
+		return nil
+	}
+	expiry := time.Duration(ps.conf.CacheExpiry) * time.Minute
+	for name, c := range ps.cacheLayers() {
+		items, err := loadDiskCache(name, expiry)
+		if err != nil {
+			return err
+		}
+		for k, item := range items {
+			c.Set(k, ps.refreshLocalState(item.Object), time.Until(time.Unix(0, item.Expiration)))
+		}
+	}
+	return nil
+}
+
+// stores our in-memory caches on disk
+func (ps *UI) saveCaches() error {
+	if ps.conf.DisableCache || !ps.conf.EnableDiskCache {
+		return nil
+	}
+	for name, c := range ps.cacheLayers() {
+		if err := saveDiskCache(name, c.Items()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wipes the in-memory and on-disk caches
+func (ps *UI) wipeCaches() error {
+	for _, c := range ps.cacheLayers() {
+		c.Flush()
+	}
+	return wipeDiskCache()
+}
+
+// updates install states of cached data which might have changed since it was stored
+func (ps *UI) refreshLocalState(obj any) any {
+	switch v := obj.(type) {
+	case []Package:
+		addPackageInstallState(ps.alpmHandle, v)
+	case InfoRecord:
+		records := []InfoRecord{v}
+		addInstallState(ps.alpmHandle, records...)
+		addLocalSatisfiers(ps.alpmHandle, records...)
+		return records[0]
+	}
+	return obj
+}
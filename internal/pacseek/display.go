@@ -1,25 +1,31 @@
 package pacseek
 
 import (
+	"context"
 	"os/exec"
 	"sort"
 	"strings"
 	"time"
 
-	"github.com/Jguer/go-alpm/v2"
 	"github.com/gdamore/tcell/v2"
 	"github.com/lithammer/fuzzysearch/fuzzy"
-	"github.com/moson-mo/pacseek/internal/util"
 	"github.com/rivo/tview"
 )
 
-// gets packages from repos/AUR and displays them
+// gets packages from all package sources and displays them
 func (ps *UI) displayPackages(text string) {
 	var packages []Package
+	ps.orphansShown = false
+
+	// we rank by the search-term without qualifiers like "repo:aur"; invalid queries are reported by the search
+	term := text
+	if q, err := parseSearchQuery(text); err == nil {
+		term = q.Term
+	}
 
 	showFunc := func() {
 		ps.shownPackages = packages
-		best := bestMatch(text, packages) + 1
+		best := bestMatch(term, packages) + 1
 		ps.drawPackageListContent(packages, ps.conf.PackageColumnWidth)
 		if ps.flexRight.GetItem(0) == ps.formSettings {
 			ps.flexRight.Clear()
@@ -30,59 +36,28 @@ func (ps *UI) displayPackages(text string) {
 
 	// check cache first
 	if packagesCache, found := ps.cacheSearch.Get(text); found {
+		ps.jobs.cancel(jobSearch)
 		packages = packagesCache.([]Package)
 		showFunc()
 		return
 	}
 
-	go func() {
-		ps.locker.Lock()
+	ps.jobs.run(jobSearch, func(ctx context.Context) {
 		ps.startSpinner()
-		defer func() {
-			ps.locker.Unlock()
-			ps.stopSpinner()
-		}()
-
-		var err error
-		var localPackages []Package
+		defer ps.stopSpinner()
 
-		// search repositories
-		packages, localPackages, err = searchRepos(ps.alpmHandle, text, ps.conf.SearchMode, ps.conf.SearchBy, ps.conf.MaxResults)
-		if err != nil {
+		// search all package sources
+		var errs []error
+		packages, errs = ps.searchSources(ctx, text)
+		if ctx.Err() != nil {
+			return
+		}
+		for _, err := range errs {
+			err := err
 			ps.app.QueueUpdateDraw(func() {
 				ps.displayMessage(err.Error(), true)
 			})
 		}
-		// search AUR
-		if !ps.conf.DisableAur {
-			aurPackages, err := searchAur(ps.conf.AurRpcUrl, text, ps.conf.AurTimeout, ps.conf.SearchMode, ps.conf.SearchBy, ps.conf.MaxResults)
-			if err != nil {
-				ps.app.QueueUpdateDraw(func() {
-					ps.displayMessage(err.Error(), true)
-				})
-			}
-
-			for i := 0; i < len(aurPackages); i++ {
-				aurPackages[i].IsInstalled = isPackageInstalled(ps.alpmHandle, aurPackages[i].Name)
-			}
-
-			// add AUR results to our list
-			packages = append(packages, aurPackages...)
-		}
-
-		// add local-only (not found in repo not AUR)
-		for _, lpkg := range localPackages {
-			found := false
-			for _, pkg := range packages {
-				if pkg.Name == lpkg.Name {
-					found = true
-					break
-				}
-			}
-			if !found {
-				packages = append(packages, lpkg)
-			}
-		}
 
 		// show message if we couldn't find anything
 		if len(packages) == 0 {
@@ -92,10 +67,8 @@ func (ps *UI) displayPackages(text string) {
 			return
 		}
 
-		// sort list by name
-		sort.Slice(packages, func(i, j int) bool {
-			return packages[i].Name < packages[j].Name
-		})
+		// sort list by name (or by rank in fuzzy mode)
+		sortPackages(packages, term, ps.conf.SearchMode)
 
 		// strip down list to our configured maximum
 		if len(packages) > ps.conf.MaxResults {
@@ -103,35 +76,42 @@ func (ps *UI) displayPackages(text string) {
 		}
 
 		// get info records and store in cache
-		ps.cacheSearchAndPackageInfo(packages, text)
+		ps.cacheSearchAndPackageInfo(ctx, packages, text)
+		if ctx.Err() != nil {
+			return
+		}
 
 		// draw packages
 		ps.app.QueueUpdateDraw(func() {
 			showFunc()
 		})
-	}()
+	})
 }
 
 // retrieves package info records and stores search results and infos in cache
-func (ps *UI) cacheSearchAndPackageInfo(packages []Package, searchTerm string) {
-	// get string slices for AUR and repo packages
-	aurPkgs := []string{}
-	repoPkgs := []string{}
+func (ps *UI) cacheSearchAndPackageInfo(ctx context.Context, packages []Package, searchTerm string) {
+	// group package names by their source
+	sourcePkgs := map[PackageSource][]string{}
 	for _, pkg := range packages {
-		if pkg.Source == "AUR" {
-			aurPkgs = append(aurPkgs, pkg.Name)
-		} else {
-			repoPkgs = append(repoPkgs, pkg.Name)
+		if src := ps.sourceFor(pkg.Source); src != nil {
+			sourcePkgs[src] = append(sourcePkgs[src], pkg.Name)
 		}
 	}
 
 	// get detailed package information for all packages and add to cache
 	if !ps.conf.DisableCache {
-		aurInfos := ps.getInfo("AUR", aurPkgs...)
-		repoInfos := ps.getInfo("repo", repoPkgs...)
-
-		for _, pkg := range append(aurInfos.Results, repoInfos.Results...) {
-			ps.cacheInfo.Set(pkg.Name+"-"+pkg.Source, pkg, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+		for src, pkgs := range sourcePkgs {
+			infos := src.Info(ctx, pkgs...)
+			if ctx.Err() != nil {
+				return
+			}
+			ps.locker.Lock()
+			addInstallState(ps.alpmHandle, infos.Results...)
+			addLocalSatisfiers(ps.alpmHandle, infos.Results...)
+			ps.locker.Unlock()
+			for _, pkg := range infos.Results {
+				ps.cacheInfo.Set(pkg.Name+"-"+pkg.Source, pkg, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+			}
 		}
 
 		ps.cacheSearch.Set(searchTerm, packages, time.Duration(ps.conf.CacheExpiry)*time.Minute)
@@ -140,13 +120,14 @@ func (ps *UI) cacheSearchAndPackageInfo(packages []Package, searchTerm string) {
 
 // retrieves package information from repo/AUR and displays them
 func (ps *UI) displayPackageInfo(row, column int) {
-	if row == -1 || row+1 > ps.tablePackages.GetRowCount() {
+	p, ok := ps.packageAt(row)
+	if !ok {
 		return
 	}
 	ps.tableDetails.Clear().
 		SetTitle("")
-	pkg := ps.tablePackages.GetCell(row, 0).Text
-	source := ps.tablePackages.GetCell(row, 1).Text
+	pkg := p.Name
+	source := p.Source
 
 	info := SearchResults{}
 
@@ -168,14 +149,19 @@ func (ps *UI) displayPackageInfo(row, column int) {
 	}
 
 	if infoCached, found := ps.cacheInfo.Get(pkg + "-" + source); found {
+		ps.jobs.cancel(jobInfo)
 		info.Results = []InfoRecord{infoCached.(InfoRecord)}
 		showFunc()
 		return
 	}
 
-	go func() {
-		if source == "AUR" {
-			time.Sleep(time.Duration(ps.conf.AurSearchDelay) * time.Millisecond)
+	ps.jobs.run(jobInfo, func(ctx context.Context) {
+		if source == "AUR" && ps.conf.AurDataSource == aurSourceRpc {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(ps.conf.AurSearchDelay) * time.Millisecond):
+			}
 		}
 
 		if !ps.isPackageSelected(pkg, true) {
@@ -186,14 +172,13 @@ func (ps *UI) displayPackageInfo(row, column int) {
 			ps.tableDetails.SetTitle(" [::b]" + pkg + " - Retrieving data... ")
 		})
 
-		ps.locker.Lock()
 		ps.startSpinner()
-		defer func() {
-			ps.locker.Unlock()
-			ps.stopSpinner()
-		}()
+		defer ps.stopSpinner()
 
-		info = ps.getInfo(source, pkg)
+		info = ps.getInfo(ctx, source, pkg)
+		if ctx.Err() != nil {
+			return
+		}
 		if !ps.conf.DisableCache && len(info.Results) == 1 {
 			ps.cacheInfo.Set(pkg+"-"+info.Results[0].Source, info.Results[0], time.Duration(ps.conf.CacheExpiry)*time.Minute)
 		}
@@ -202,7 +187,7 @@ func (ps *UI) displayPackageInfo(row, column int) {
 		ps.app.QueueUpdateDraw(func() {
 			showFunc()
 		})
-	}()
+	})
 }
 
 // displays status bar with error message
@@ -242,8 +227,18 @@ func (ps *UI) displayHelp() {
 		SetCellSimple(10, 0, "CTRL+O: Open URL for selected package").
 		SetCellSimple(11, 0, "CTRL+G: Show list of upgradeable packages").
 		SetCellSimple(12, 0, "CTRL+L: Show list of all installed packages").
-		SetCellSimple(14, 0, "CTRL+Q / ESC: Quit").
-		SetCell(16, 0, &tview.TableCell{
+		SetCellSimple(13, 0, "CTRL+T / CTRL+R: Show / remove orphaned packages").
+		SetCellSimple(14, 0, "SPACE: Add/remove selected package to/from queue").
+		SetCellSimple(15, 0, "CTRL+X: Install/remove queued packages").
+		SetCellSimple(16, 0, "CTRL+F: Show files of selected (installed) package").
+		SetCellSimple(17, 0, "CTRL+D: Switch install reason (explicit / dependency)").
+		SetCellSimple(18, 0, "CTRL+Y / CTRL+V: Show dependency / reverse dependency tree").
+		SetCellSimple(19, 0, "CTRL+E: Show / hide output of AUR builds (outside of the search field)").
+		SetCellSimple(20, 0, "CTRL+K: Show git history of selected package (outside of the search field)").
+		SetCellSimple(21, 0, "CTRL+Z: Show comments of selected AUR package").
+		SetCellSimple(22, 0, "w / ALT+W: Add/remove selected package to/from watchlist / Show watchlist").
+		SetCellSimple(23, 0, "CTRL+Q / ESC: Quit").
+		SetCell(25, 0, &tview.TableCell{
 			Text:            "For detailed instructions, please check the man page or visit the [::b]Wiki",
 			Color:           tcell.ColorWhite,
 			BackgroundColor: ps.conf.Colors().DefaultBackground,
@@ -302,22 +297,38 @@ func (ps *UI) displayPkgbuild() {
 		AddItem(ps.textPkgbuild, 0, 1, true)
 	ps.app.SetFocus(ps.textPkgbuild)
 
-	// check cache first
-	if contentCached, found := ps.cachePkgbuild.Get(pkg.PackageBase); found {
-		content := contentCached.(string)
-		ps.drawPkgbuild(content, pkg.Name)
-		return
-	}
+	// check cache first; for AUR packages we look for changes since the installed version
+	contentCached, found := ps.cachePkgbuild.Get(pkg.PackageBase)
+	ps.repoFiles = nil
 
-	go func() {
-		ps.locker.Lock()
+	ps.jobs.run(jobPkgbuild, func(ctx context.Context) {
 		ps.startSpinner()
-		defer func() {
-			ps.locker.Unlock()
-			ps.stopSpinner()
-		}()
+		defer ps.stopSpinner()
 
-		content, err := getPkgbuildContent(getPkgbuildUrl(pkg.Source, pkg.PackageBase))
+		var content, diff, since string
+		var err, diffErr error
+		if found {
+			content = contentCached.(string)
+		} else {
+			content, err = getPkgbuildContent(ctx, ps.pkgbuildUrl(pkg.Source, pkg.PackageBase))
+		}
+		// failing to compare versions should not prevent us from showing the PKGBUILD
+		if err == nil {
+			diff, since, diffErr = ps.pkgbuildChanges(ctx, pkg, content)
+		}
+		// a missing file list should not prevent us from showing the PKGBUILD
+		var files []string
+		if err == nil && hasRepoFiles(pkg.Source) {
+			files, _ = ps.getRepoFiles(ctx, pkg.Source, pkg.PackageBase)
+		}
+		var findings []lintFinding
+		lint := pkg.Source == "AUR" && !ps.conf.DisablePkgbuildLinter
+		if lint {
+			findings = lintPkgbuild(content, ps.lintRules)
+		}
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
 			ps.app.QueueUpdateDraw(func() {
 				ps.textPkgbuild.SetTitle(" [::b]Error loading PKGBUILD ")
@@ -325,13 +336,42 @@ func (ps *UI) displayPkgbuild() {
 			})
 			return
 		}
-		if !ps.conf.DisableCache {
+		if !ps.conf.DisableCache && !found {
 			ps.cachePkgbuild.Set(pkg.PackageBase, content, time.Duration(ps.conf.CacheExpiry)*time.Minute)
 		}
 		ps.app.QueueUpdateDraw(func() {
-			ps.drawPkgbuild(content, pkg.Name)
+			offset := 0
+			if diff != "" {
+				ps.drawPkgbuildDiff(diff, since, content, pkg.Name)
+				offset = strings.Count(colorDiff(diff)+pkgbuildDiffSeparator, "\n")
+			} else {
+				ps.drawPkgbuild(content, pkg.Name)
+			}
+			if diffErr != nil {
+				ps.displayMessage("failed to check for PKGBUILD changes: "+diffErr.Error(), true)
+			}
+			if ps.flexRight.GetItem(0) != ps.textPkgbuild {
+				return
+			}
+			if len(files) > 0 {
+				ps.drawRepoFiles(files)
+			}
+			if lint {
+				ps.drawPkgbuildFindings(findings, offset)
+			}
 		})
-	}()
+	})
+}
+
+// focuses the next component of our right container (e.g. PKGBUILD -> files -> findings) and the search after the last one
+func (ps *UI) focusNextItem() {
+	for i := 0; i < ps.flexRight.GetItemCount()-1; i++ {
+		if ps.flexRight.GetItem(i).HasFocus() {
+			ps.app.SetFocus(ps.flexRight.GetItem(i + 1))
+			return
+		}
+	}
+	ps.app.SetFocus(ps.inputSearch)
 }
 
 // checks if a given package is currently selected in the package list
@@ -339,7 +379,8 @@ func (ps *UI) isPackageSelected(pkg string, queue bool) bool {
 	var sel string
 	f := func() {
 		crow, _ := ps.tablePackages.GetSelection()
-		sel = ps.tablePackages.GetCell(crow, 0).Text
+		p, _ := ps.packageAt(crow)
+		sel = p.Name
 	}
 
 	if queue {
@@ -363,13 +404,14 @@ func (ps *UI) displayUpgradable() {
 		return
 	}
 
-	go func() {
-		ps.locker.Lock()
+	ps.jobs.run(jobUpgrades, func(ctx context.Context) {
 		ps.startSpinner()
 		defer ps.stopSpinner()
-		defer ps.locker.Unlock()
 
-		h, err := syncToTempDB(ps.conf.PacmanConfigPath, ps.filterRepos)
+		foundUp, err := ps.findUpgrades(ctx)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
 			ps.app.QueueUpdateDraw(func() {
 				ps.tableDetails.SetTitle(" [::b]Error ")
@@ -386,42 +428,24 @@ func (ps *UI) displayUpgradable() {
 			})
 			return
 		}
-
-		up, nf := getUpgradable(h, ps.conf.ComputeRequiredBy)
-		aurPkgs := infoAur(ps.conf.AurRpcUrl, ps.conf.AurTimeout, nf...)
-		for _, aurPkg := range aurPkgs.Results {
-			for i := 0; i < len(up); i++ {
-				if up[i].Source == "local" && up[i].Name == aurPkg.Name {
-					if alpm.VerCmp(aurPkg.Version, up[i].LocalVersion) > 0 {
-						up[i].Description = aurPkg.Description
-						up[i].Version = aurPkg.Version
-						up[i].Source = "AUR"
-					}
-				}
-			}
-		}
-		foundUp := []InfoRecord{}
-		for _, pkg := range up {
-			if pkg.Version != pkg.LocalVersion {
-				foundUp = append(foundUp, pkg)
-			}
-		}
 		if !ps.conf.DisableCache {
 			ps.cacheInfo.Set("#upgrades#", foundUp, time.Duration(ps.conf.CacheExpiry)*time.Minute)
 		}
 		ps.app.QueueUpdateDraw(func() {
 			ps.drawUpgradable(foundUp, false)
 		})
-	}()
+	})
 }
 
 // displays list of installed packages
 func (ps *UI) displayInstalled(displayUpdatesAfter bool) {
+	ps.orphansShown = false
 	ps.tablePackages.Clear().
 		SetCellSimple(0, 0, "Generating list, please wait...")
 
 	// search cache
 	if installedCached, found := ps.cacheSearch.Get("#installed#"); found {
+		ps.jobs.cancel(jobSearch)
 		packages := installedCached.([]Package)
 		ps.shownPackages = packages
 		ps.drawPackageListContent(packages, ps.conf.PackageColumnWidth)
@@ -429,16 +453,17 @@ func (ps *UI) displayInstalled(displayUpdatesAfter bool) {
 		return
 	}
 
-	go func() {
-		ps.locker.Lock()
+	ps.jobs.run(jobSearch, func(ctx context.Context) {
 		ps.startSpinner()
-		defer func() {
-			ps.locker.Unlock()
-			ps.stopSpinner()
-		}()
+		defer ps.stopSpinner()
 
+		ps.locker.Lock()
 		in, nf := getInstalled(ps.alpmHandle, ps.conf.ComputeRequiredBy)
-		aurPkgs := ps.getInfo("AUR", nf...).Results
+		ps.locker.Unlock()
+		aurPkgs := ps.getInfo(ctx, "AUR", nf...).Results
+		if ctx.Err() != nil {
+			return
+		}
 		for _, aurPkg := range aurPkgs {
 			for i := 0; i < len(in); i++ {
 				if in[i].Source == "local" && in[i].Name == aurPkg.Name {
@@ -460,6 +485,12 @@ func (ps *UI) displayInstalled(displayUpdatesAfter bool) {
 				IsInstalled:  true,
 				LastModified: pkg.LastModified,
 				Popularity:   pkg.Popularity,
+				Size:         pkg.LocalSize,
+				Version:      pkg.Version,
+				LocalVersion: pkg.LocalVersion,
+				NumVotes:     pkg.NumVotes,
+				OutOfDate:    pkg.OutOfDate,
+				Maintainer:   pkg.Maintainer,
 			})
 			if !ps.conf.DisableCache {
 				ps.cacheInfo.Set(pkg.Name+"-"+pkg.Source, pkg, time.Duration(ps.conf.CacheExpiry)*time.Minute)
@@ -477,7 +508,7 @@ func (ps *UI) displayInstalled(displayUpdatesAfter bool) {
 				ps.tablePackages.Select(1, 0)
 			}
 		})
-	}()
+	})
 }
 
 // auto-complete function for our input field
@@ -488,14 +519,12 @@ func (ps *UI) autoComplete(text string) []string {
 			return cached.([]string)
 		}
 
-		go func() {
-			ps.locker.Lock()
-			defer ps.locker.Unlock()
-			repo := suggestRepos(ps.alpmHandle, text)
-			aur := suggestAur(ps.conf.AurRpcUrl, text, ps.conf.AurTimeout)
-
+		ps.jobs.run(jobSuggest, func(ctx context.Context) {
 			max := 20
-			results := util.UniqueStrings(repo, aur)
+			results := ps.suggestSources(ctx, text)
+			if ctx.Err() != nil {
+				return
+			}
 			sort.Strings(results)
 			if len(results) < max {
 				max = len(results)
@@ -505,7 +534,7 @@ func (ps *UI) autoComplete(text string) []string {
 
 			ps.inputSearch.Autocomplete()
 			ps.app.Draw()
-		}()
+		})
 	}
 	return []string{}
 }
@@ -3,7 +3,6 @@ package pacseek
 import (
 	"fmt"
 	"os/exec"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,14 +17,8 @@ import (
 // draws input fields on settings form
 func (ps *UI) drawSettingsFields(disableAur, disableCache, separateAurCommands, pkgbuildInternal, disableFeed bool) {
 	ps.formSettings.Clear(false)
-	mode := 0
-	if ps.conf.SearchMode != "StartsWith" {
-		mode = 1
-	}
-	by := 0
-	if ps.conf.SearchBy != "Name" {
-		by = 1
-	}
+	mode := util.IndexOf(searchModes(), ps.conf.SearchMode)
+	by := util.IndexOf(searchByOptions(), ps.conf.SearchBy)
 	cIndex := util.IndexOf(config.ColorSchemes(), ps.conf.ColorScheme)
 	bIndex := util.IndexOf(config.BorderStyles(), ps.conf.BorderStyle)
 	gIndex := util.IndexOf(config.GlyphStyles(), ps.conf.GlyphStyle)
@@ -84,7 +77,14 @@ func (ps *UI) drawSettingsFields(disableAur, disableCache, separateAurCommands,
 	if !disableAur {
 		ps.formSettings.AddInputField("AUR RPC URL: ", ps.conf.AurRpcUrl, 40, nil, sc).
 			AddInputField("AUR timeout (ms): ", strconv.Itoa(ps.conf.AurTimeout), 6, nil, sc).
-			AddInputField("AUR search delay (ms): ", strconv.Itoa(ps.conf.AurSearchDelay), 6, nil, sc)
+			AddInputField("AUR search delay (ms): ", strconv.Itoa(ps.conf.AurSearchDelay), 6, nil, sc).
+			AddDropDown("AUR data source: ", aurDataSources(), util.IndexOf(aurDataSources(), ps.conf.AurDataSource), func(text string, index int) {
+				if text != ps.conf.AurDataSource {
+					ps.settingsChanged = true
+				}
+			}).
+			AddInputField("AUR dump URL: ", ps.conf.AurDumpUrl, 40, nil, sc).
+			AddInputField("AUR dump refresh (h): ", strconv.Itoa(ps.conf.AurDumpRefresh), 6, nil, sc)
 	}
 	ps.formSettings.AddCheckbox("Disable Cache: ", disableCache, func(checked bool) {
 		ps.settingsChanged = true
@@ -94,15 +94,18 @@ func (ps *UI) drawSettingsFields(disableAur, disableCache, separateAurCommands,
 		ps.app.SetFocus(ps.formSettings)
 	})
 	if !disableCache {
-		ps.formSettings.AddInputField("Cache expiry (m): ", strconv.Itoa(ps.conf.CacheExpiry), 6, nil, sc)
+		ps.formSettings.AddInputField("Cache expiry (m): ", strconv.Itoa(ps.conf.CacheExpiry), 6, nil, sc).
+			AddCheckbox("Persist cache on disk: ", ps.conf.EnableDiskCache, func(checked bool) {
+				ps.settingsChanged = true
+			})
 	}
 	ps.formSettings.AddInputField("Max search results: ", strconv.Itoa(ps.conf.MaxResults), 6, nil, sc).
-		AddDropDown("Search mode: ", []string{"StartsWith", "Contains"}, mode, func(text string, index int) {
+		AddDropDown("Search mode: ", searchModes(), mode, func(text string, index int) {
 			if text != ps.conf.SearchMode {
 				ps.settingsChanged = true
 			}
 		}).
-		AddDropDown("Search by: ", []string{"Name", "Name & Description"}, by, func(text string, index int) {
+		AddDropDown("Search by: ", searchByOptions(), by, func(text string, index int) {
 			if text != ps.conf.SearchBy {
 				ps.settingsChanged = true
 			}
@@ -134,9 +137,22 @@ func (ps *UI) drawSettingsFields(disableAur, disableCache, separateAurCommands,
 		ps.formSettings.AddInputField("AUR Install command: ", icom, 40, nil, sc).
 			AddInputField("AUR Upgrade command: ", ucom, 40, nil, sc)
 	}
+	ps.formSettings.AddCheckbox("Build AUR packages internally: ", ps.conf.AurBuildInternally, func(checked bool) {
+		ps.settingsChanged = true
+	}).
+		AddCheckbox("Clean build: ", ps.conf.AurCleanBuild, func(checked bool) {
+			ps.settingsChanged = true
+		}).
+		AddCheckbox("Disable PKGBUILD linter: ", ps.conf.DisablePkgbuildLinter, func(checked bool) {
+			ps.settingsChanged = true
+		})
 	ps.formSettings.AddInputField("Install command: ", ps.conf.InstallCommand, 40, nil, sc).
 		AddInputField("Upgrade command: ", ps.conf.SysUpgradeCommand, 40, nil, sc).
 		AddInputField("Uninstall command: ", ps.conf.UninstallCommand, 40, nil, sc).
+		AddInputField("Install reason command: ", ps.conf.InstallReasonCommand, 40, nil, sc).
+		AddCheckbox("Disable transaction preview: ", ps.conf.DisableTransactionPreview, func(checked bool) {
+			ps.settingsChanged = true
+		}).
 		AddCheckbox("Show PKGBUILD internally: ", pkgbuildInternal, func(checked bool) {
 			ps.settingsChanged = true
 			i, _ := ps.formSettings.GetFocusedItemIndex()
@@ -165,6 +181,7 @@ func (ps *UI) drawSettingsFields(disableAur, disableCache, separateAurCommands,
 		width, _ := strconv.Atoi(text)
 		ps.drawPackageListContent(ps.shownPackages, width)
 	})
+	ps.formSettings.AddInputField("Package list columns: ", strings.Join(ps.conf.PackageListColumns, ", "), 40, nil, sc)
 	ps.formSettings.AddCheckbox("Separate Deps with Newline: ", ps.conf.SepDepsWithNewLine, func(checked bool) {
 		ps.settingsChanged = true
 	})
@@ -271,6 +288,9 @@ func (ps *UI) drawPackageInfo(i InfoRecord, width int) {
 				} else {
 					lines = []string{}
 				}
+				if i.Source == "AUR" && ps.conf.ShowPkgbuildInternally && !ps.conf.DisablePkgbuildLinter {
+					ps.lintDetails(i, r)
+				}
 			}
 			ps.tableDetails.SetCell(r, 0, cell)
 
@@ -368,6 +388,17 @@ func (ps *UI) drawUpgradable(up []InfoRecord, cached bool) {
 			BackgroundColor: ps.conf.Colors().DefaultBackground,
 		})
 	} else {
+		download, delta, aur := upgradeSizes(up)
+		totals := fmt.Sprintf("Download size: %s, net upgrade size: %s", formatSize(download), formatSizeDelta(delta))
+		if aur {
+			totals += " (without AUR packages)"
+		}
+		ps.tableDetails.SetCell(r, 0, &tview.TableCell{
+			Text:            totals,
+			Color:           ps.conf.Colors().PackagelistHeader,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+		})
+		r += 2
 		ps.tableDetails.SetCell(r, 0, &tview.TableCell{
 			Text:            " [::b]Sysupgrade",
 			Align:           tview.AlignCenter,
@@ -481,9 +512,10 @@ func (ps *UI) drawUpgradeableLine(up InfoRecord, lNum int, ignored bool) {
 			Color:           ps.conf.Colors().SettingsFieldText,
 			BackgroundColor: ps.conf.Colors().SearchBar,
 			Clicked: func() bool {
-				ps.installPackage(up, false)
-				ps.cacheInfo.Delete("#upgrades#")
-				ps.displayUpgradable()
+				ps.installPackage(up, false, func() {
+					ps.cacheInfo.Delete("#upgrades#")
+					ps.displayUpgradable()
+				})
 				return true
 			},
 		}
@@ -511,32 +543,39 @@ func (ps *UI) drawPackageListContent(packages []Package, pkgwidth int) {
 
 	// rows
 	for i, pkg := range packages {
-		color := ps.conf.Colors().PackagelistSourceRepository
-
-		if pkg.Source == "AUR" {
-			color = ps.conf.Colors().PackagelistSourceAUR
-		}
+		ps.drawPackageListRow(i+1, pkg, pkgwidth)
+	}
+	ps.tablePackages.ScrollToBeginning()
+}
 
-		ps.tablePackages.SetCell(i+1, 0, &tview.TableCell{
-			Text:            pkg.Name,
+// draws the cells of a package in our list
+func (ps *UI) drawPackageListRow(row int, pkg Package, pkgwidth int) {
+	for i, col := range ps.columns {
+		cell := &tview.TableCell{
+			Text:            col.text(ps, pkg),
 			Color:           tcell.ColorWhite,
 			BackgroundColor: ps.conf.Colors().DefaultBackground,
-			MaxWidth:        pkgwidth,
-		}).
-			SetCell(i+1, 1, &tview.TableCell{
-				Text:            pkg.Source,
-				Color:           color,
-				BackgroundColor: ps.conf.Colors().DefaultBackground,
-			}).
-			SetCell(i+1, 2, &tview.TableCell{
-				Color:       ps.conf.Colors().DefaultBackground,
-				Text:        ps.getInstalledStateText(pkg.IsInstalled),
-				Expansion:   1000,
-				Reference:   pkg.IsInstalled,
-				Transparent: true,
-			})
+			Align:           col.Align,
+			MaxWidth:        col.Width,
+			Transparent:     col.Transparent,
+		}
+		if col.color != nil {
+			cell.SetTextColor(col.color(ps, pkg))
+		}
+		if col.Name == "Name" {
+			cell.SetMaxWidth(pkgwidth)
+		}
+		// the first cell holds our package so we can look it up later on
+		if i == 0 {
+			cell.SetReference(pkg)
+		}
+		// the last column takes the remaining space
+		if i == len(ps.columns)-1 {
+			cell.SetExpansion(1000)
+		}
+		ps.tablePackages.SetCell(row, i, cell)
 	}
-	ps.tablePackages.ScrollToBeginning()
+	ps.applyQueuedStyle(row)
 }
 
 // draw pkgbuild on screen
@@ -550,31 +589,57 @@ func (ps *UI) drawPkgbuild(content, pkg string) {
 	ps.textPkgbuild.ScrollToBeginning()
 }
 
+// draw a file of a package's git repository
+func (ps *UI) drawRepoFile(content, file, pkg string) {
+	title := " [::b]" + ps.conf.Glyphs().Pkgbuild + tview.Escape(file) + " - " + pkg + " "
+	if isInstallScript(file) {
+		title += "- [red]runs as root[-] "
+	}
+	ps.textPkgbuild.Clear().
+		SetTitle(title)
+	err := quick.Highlight(ps.pkgbuildWriter, tview.Escape(content), repoFileLexer(file), "terminal16m", ps.conf.Colors().StylePKGBUILD)
+	if err != nil {
+		ps.textPkgbuild.SetText(err.Error())
+		return
+	}
+	ps.textPkgbuild.ScrollToBeginning()
+}
+
+// draw the changes of a PKGBUILD followed by the new PKGBUILD
+func (ps *UI) drawPkgbuildDiff(diff, since, content, pkg string) {
+	ps.textPkgbuild.SetTitle(" [::b]" + ps.conf.Glyphs().Pkgbuild + "PKGBUILD - " + pkg + " - changes since " + since + " ")
+	fmt.Fprint(ps.textPkgbuild, colorDiff(diff))
+	fmt.Fprint(ps.textPkgbuild, pkgbuildDiffSeparator)
+	err := quick.Highlight(ps.pkgbuildWriter, tview.Escape(content), "bash", "terminal16m", ps.conf.Colors().StylePKGBUILD)
+	if err != nil {
+		ps.textPkgbuild.SetText(err.Error())
+		return
+	}
+	ps.textPkgbuild.ScrollToBeginning()
+}
+
 // adds header row to package table
 func (ps *UI) drawPackageListHeader(pkgwidth int) {
-	columns := []string{"Package", "Source", "Installed"}
-	for i, col := range columns {
+	for i, col := range ps.columns {
 		col := col
-		width := 0
-		if i == 0 {
+		text := col.Name
+		if col.Title != "" {
+			text = col.Title
+		}
+		width := col.Width
+		if col.Name == "Name" {
 			width = pkgwidth
-			col = fmt.Sprintf("%-"+strconv.Itoa(width)+"s", col)
+			text = fmt.Sprintf("%-"+strconv.Itoa(width)+"s", text)
 		}
 		ps.tablePackages.SetCell(0, i, &tview.TableCell{
-			Text:            col,
+			Text:            text,
 			NotSelectable:   true,
 			Color:           ps.conf.Colors().PackagelistHeader,
 			BackgroundColor: ps.conf.Colors().DefaultBackground,
+			Align:           col.Align,
 			MaxWidth:        width,
 			Clicked: func() bool {
-				switch col {
-				case "Package":
-					ps.sortAndRedrawPackageList('N')
-				case "Source":
-					ps.sortAndRedrawPackageList('S')
-				case "Installed":
-					ps.sortAndRedrawPackageList('I')
-				}
+				ps.sortAndRedrawPackageList(col.Key)
 				return true
 			},
 		})
@@ -583,77 +648,11 @@ func (ps *UI) drawPackageListHeader(pkgwidth int) {
 
 // sorts and redraws the list of packages
 func (ps *UI) sortAndRedrawPackageList(runeKey rune) {
-	// n - sort by name
-	switch runeKey {
-	case 'N': // sort by name
-		if ps.sortAscending {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				return ps.shownPackages[i].Name > ps.shownPackages[j].Name
-			})
-		} else {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				return ps.shownPackages[j].Name > ps.shownPackages[i].Name
-			})
-		}
-	case 'S': // sort by source
-		if ps.sortAscending {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				if ps.shownPackages[i].Source == ps.shownPackages[j].Source {
-					return ps.shownPackages[j].Name > ps.shownPackages[i].Name
-				}
-				return ps.shownPackages[i].Source > ps.shownPackages[j].Source
-			})
-		} else {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				if ps.shownPackages[i].Source == ps.shownPackages[j].Source {
-					return ps.shownPackages[j].Name > ps.shownPackages[i].Name
-				}
-				return ps.shownPackages[j].Source > ps.shownPackages[i].Source
-			})
-		}
-	case 'I': // sort by installed state
-		if ps.sortAscending {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				if ps.shownPackages[i].IsInstalled == ps.shownPackages[j].IsInstalled {
-					return ps.shownPackages[j].Name > ps.shownPackages[i].Name
-				}
-				return ps.shownPackages[i].IsInstalled
-			})
-		} else {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				if ps.shownPackages[i].IsInstalled == ps.shownPackages[j].IsInstalled {
-					return ps.shownPackages[j].Name > ps.shownPackages[i].Name
-				}
-				return ps.shownPackages[j].IsInstalled
-			})
-		}
-	case 'M': // sort by last modified date
-		if ps.sortAscending {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				return ps.shownPackages[i].LastModified > ps.shownPackages[j].LastModified
-			})
-		} else {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				return ps.shownPackages[j].LastModified > ps.shownPackages[i].LastModified
-			})
-		}
-	case 'P': // sort by popularity
-		if ps.sortAscending {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				if ps.shownPackages[i].Popularity == ps.shownPackages[j].Popularity {
-					return ps.shownPackages[j].Name > ps.shownPackages[i].Name
-				}
-				return ps.shownPackages[i].Popularity > ps.shownPackages[j].Popularity
-			})
-		} else {
-			sort.Slice(ps.shownPackages, func(i, j int) bool {
-				if ps.shownPackages[i].Popularity == ps.shownPackages[j].Popularity {
-					return ps.shownPackages[j].Name > ps.shownPackages[i].Name
-				}
-				return ps.shownPackages[j].Popularity > ps.shownPackages[i].Popularity
-			})
-		}
+	col, ok := packageColumnByKey(runeKey)
+	if !ok {
+		return
 	}
+	sortPackagesBy(ps.shownPackages, col, ps.sortAscending)
 	ps.sortAscending = !ps.sortAscending
 	ps.drawPackageListContent(ps.shownPackages, ps.conf.PackageColumnWidth)
 	ps.tablePackages.Select(1, 0)
@@ -663,12 +662,17 @@ func (ps *UI) sortAndRedrawPackageList(runeKey rune) {
 func (ps *UI) getDetailFields(i InfoRecord) (map[string]string, []string) {
 	order := []string{
 		"Description",
+		"Matching files",
 		"Version",
 		"Maintainer",
 		"Licenses",
 		"Votes",
 		"Popularity",
 		"Last modified",
+		"Download size",
+		"Installed size",
+		"Install reason",
+		"Installed on",
 		"Flagged out of date",
 		"URL",
 		"Package URL",
@@ -682,6 +686,7 @@ func (ps *UI) getDetailFields(i InfoRecord) (map[string]string, []string) {
 	fields := map[string]string{}
 	fields["Description"] = i.Description
 	fields["Version"] = i.Version
+	fields["Matching files"] = strings.Join(ps.matchingFilesFor(i.Name, i.Source), "\n")
 	fields["Provides"] = strings.Join(i.Provides, ", ")
 	fields["Conflicts"] = strings.Join(i.Conflicts, ", ")
 	fields["Licenses"] = strings.Join(i.License, ", ")
@@ -704,6 +709,16 @@ func (ps *UI) getDetailFields(i InfoRecord) (map[string]string, []string) {
 	if i.LastModified != 0 {
 		fields["Last modified"] = time.Unix(int64(i.LastModified), 0).UTC().Format("2006-01-02 - 15:04:05 (UTC)")
 	}
+	if i.DownloadSize > 0 {
+		fields["Download size"] = formatSize(i.DownloadSize)
+	}
+	if i.InstalledSize > 0 {
+		fields["Installed size"] = formatSize(i.InstalledSize)
+	}
+	fields["Install reason"] = i.InstallReason
+	if i.InstallDate != 0 {
+		fields["Installed on"] = time.Unix(int64(i.InstallDate), 0).UTC().Format("2006-01-02 - 15:04:05 (UTC)")
+	}
 	if i.OutOfDate != 0 {
 		fields["Flagged out of date"] = time.Unix(int64(i.OutOfDate), 0).UTC().Format("[red]2006-01-02 - 15:04:05 (UTC)")
 	}
@@ -740,26 +755,22 @@ func getDependenciesJoined(i InfoRecord, installedIcon, notInstalledicon string,
 // updates the "install state" of all packages in cache and package list
 func (ps *UI) updateInstalledState() {
 	// update cached packages
-	sterm := strings.ToLower(ps.inputSearch.GetText())
+	sterm := normalizeSearchTerm(ps.inputSearch.GetText(), ps.conf.SearchMode)
 	cpkg, exp, found := ps.cacheSearch.GetWithExpiration(sterm)
 	if found {
 		scpkg := cpkg.([]Package)
-		for i := 0; i < len(scpkg); i++ {
-			scpkg[i].IsInstalled = isPackageInstalled(ps.alpmHandle, scpkg[i].Name)
-		}
+		addPackageInstallState(ps.alpmHandle, scpkg)
 		ps.cacheSearch.Set(sterm, scpkg, time.Until(exp))
 	}
 
 	// update currently shown packages
+	addPackageInstallState(ps.alpmHandle, ps.shownPackages)
 	for i := 1; i < ps.tablePackages.GetRowCount(); i++ {
-		isInstalled := isPackageInstalled(ps.alpmHandle, ps.tablePackages.GetCell(i, 0).Text)
-		newCell := &tview.TableCell{
-			Text:        ps.getInstalledStateText(isInstalled),
-			Expansion:   1000,
-			Reference:   isInstalled,
-			Transparent: true,
+		if pkg, ok := ps.packageAt(i); ok {
+			pkgs := []Package{pkg}
+			addPackageInstallState(ps.alpmHandle, pkgs)
+			ps.drawPackageListRow(i, pkgs[0], ps.conf.PackageColumnWidth)
 		}
-		ps.tablePackages.SetCell(i, 2, newCell)
 	}
 }
 
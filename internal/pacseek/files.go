@@ -0,0 +1,151 @@
+package pacseek
+
+import (
+	"context"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// how long we use our files databases before syncing them again
+const filesDbMaxAge = 24 * time.Hour
+
+// searches for packages containing files that match the search-term (like "pacman -F")
+func (ps *UI) searchFiles(ctx context.Context, term string) ([]Package, error) {
+	q, err := parseSearchQuery(term)
+	if err != nil {
+		return []Package{}, err
+	}
+	if !q.allowsSource(false) {
+		return []Package{}, nil
+	}
+
+	ps.filesLocker.Lock()
+	defer ps.filesLocker.Unlock()
+	// we might have been waiting for another search syncing the files databases
+	if ctx.Err() != nil {
+		return []Package{}, ctx.Err()
+	}
+	if err := ps.refreshFilesDbs(ctx); err != nil {
+		return []Package{}, err
+	}
+
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+	packages, localPackages, err := searchFiles(ps.filesHandle, ps.alpmHandle, q, ps.conf.SearchMode, ps.conf.MaxResults)
+	return append(packages, localPackages...), err
+}
+
+// syncs our files databases if they are missing or outdated
+func (ps *UI) refreshFilesDbs(ctx context.Context) error {
+	if ps.filesHandle != nil && time.Since(ps.filesSynced) < filesDbMaxAge {
+		return nil
+	}
+	h, err := syncFilesToTempDB(ctx, ps.conf.PacmanConfigPath, ps.filterRepos)
+	if err != nil {
+		return err
+	}
+	if ps.filesHandle != nil {
+		ps.filesHandle.Release()
+	}
+	ps.filesHandle = h
+	ps.filesSynced = time.Now()
+	return nil
+}
+
+// searches the file lists of the sync packages (files DB's) and installed packages (local DB)
+func searchFiles(fh, h *alpm.Handle, q searchQuery, mode string, maxResults int) ([]Package, []Package, error) {
+	packages := []Package{}
+	installed := []Package{}
+
+	// we match file names unless the search-term is a path
+	term := strings.TrimPrefix(q.Term, "/")
+	fullPath := strings.Contains(term, "/")
+	match, err := newPackageMatcher(term, mode, "Name")
+	if err != nil {
+		return packages, installed, err
+	}
+
+	dbs, err := fh.SyncDBs()
+	if err != nil {
+		return packages, installed, err
+	}
+	local, err := h.LocalDB()
+	if err != nil {
+		return packages, installed, err
+	}
+
+	counter := 0
+	for _, db := range append(dbs.Slice(), local) {
+		for _, pkg := range db.PkgCache().Slice() {
+			if counter >= maxResults {
+				break
+			}
+			files := matchingFiles(pkg.Files(), match.name, fullPath)
+			if len(files) == 0 {
+				continue
+			}
+			if len(q.Filters) > 0 && !q.matches(alpmInfoRecord(pkg, db.Name())) {
+				continue
+			}
+
+			p := Package{
+				Name:          pkg.Name(),
+				Source:        db.Name(),
+				LastModified:  int(pkg.BuildDate().Unix()),
+				Popularity:    math.MaxFloat64,
+				Size:          pkg.ISize(),
+				Version:       pkg.Version(),
+				Maintainer:    pkg.Packager(),
+				MatchingFiles: files,
+			}
+			if lpkg := local.Pkg(pkg.Name()); lpkg != nil {
+				p.IsInstalled = true
+				p.LocalVersion = lpkg.Version()
+			}
+			if !q.matchesPackage(p) {
+				continue
+			}
+			if db != local {
+				packages = append(packages, p)
+			} else {
+				installed = append(installed, p)
+			}
+
+			counter++
+		}
+	}
+	return packages, installed, nil
+}
+
+// returns the (absolute) paths of files matching our search-term
+func matchingFiles(files []alpm.File, match func(string) bool, fullPath bool) []string {
+	matches := []string{}
+	for _, f := range files {
+		// skip directories
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		name := f.Name
+		if !fullPath {
+			name = path.Base(name)
+		}
+		if match(name) {
+			matches = append(matches, "/"+f.Name)
+		}
+	}
+	return matches
+}
+
+// returns the files that matched our search-term for a package in our list
+func (ps *UI) matchingFilesFor(name, source string) []string {
+	for _, pkg := range ps.shownPackages {
+		if pkg.Name == name && pkg.Source == source {
+			return pkg.MatchingFiles
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package pacseek
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/rivo/tview"
+)
+
+// matches a commit of a cgit log page: date, commit id, message and author
+var cgitLogRegex = regexp.MustCompile(`<tr><td><span title='([^']+)'>[^<]*</span></td><td><a href='[^']*[?;]id=([0-9a-f]+)'>([^<]*)</a>.*?</td><td>([^<]*)</td>`)
+
+// number of commits we get with one request; the history is truncated if we get a full page
+const (
+	cgitLogPageSize       = 50
+	gitlabCommitsPageSize = 100 // per_page of UrlRepoCommits
+)
+
+// commit messages indicating that a package got a new maintainer
+var maintainerChangeRegex = regexp.MustCompile(`(?i)(maintainer|adopt|orphan|disown|took over|take over|taking over)`)
+
+// gitCommit is a commit of a package's git repository
+type gitCommit struct {
+	ID               string
+	Date             time.Time
+	Author           string
+	Message          string
+	NewAuthor        bool // first commit of an author we haven't seen before
+	MaintainerChange bool // the message mentions a change of maintainers
+}
+
+// an entry of the GitLab commits API
+type gitlabCommit struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	AuthorName string    `json:"author_name"`
+	Date       time.Time `json:"authored_date"`
+}
+
+// composes the URL listing the commits of a package's git repository
+func commitLogUrl(source, base string) string {
+	if util.SliceContains(getArchRepos(), source) {
+		return fmt.Sprintf(UrlRepoCommits, encodePackageGitlabUrl(base))
+	}
+	return fmt.Sprintf(UrlAurLog, base)
+}
+
+// composes the URL to the patch of a commit
+func commitPatchUrl(source, base, id string) string {
+	if util.SliceContains(getArchRepos(), source) {
+		return fmt.Sprintf(UrlRepoPatch, encodePackageGitlabUrl(base), id)
+	}
+	return fmt.Sprintf(UrlAurPatch, base, id)
+}
+
+// parses the commits of a cgit log page (newest first)
+func parseCgitLog(page string) []gitCommit {
+	commits := []gitCommit{}
+	for _, m := range cgitLogRegex.FindAllStringSubmatch(page, -1) {
+		date, _ := time.Parse("2006-01-02 15:04:05 -0700", m[1])
+		commits = append(commits, gitCommit{
+			ID:      m[2],
+			Date:    date,
+			Message: html.UnescapeString(m[3]),
+			Author:  html.UnescapeString(m[4]),
+		})
+	}
+	return flagCommits(commits, len(commits) >= cgitLogPageSize)
+}
+
+// parses the commits of the GitLab commits API (newest first)
+func parseGitlabCommits(data []byte) ([]gitCommit, error) {
+	entries := []gitlabCommit{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	commits := []gitCommit{}
+	for _, e := range entries {
+		commits = append(commits, gitCommit{
+			ID:      e.ID,
+			Date:    e.Date,
+			Message: e.Title,
+			Author:  e.AuthorName,
+		})
+	}
+	return flagCommits(commits, len(commits) >= gitlabCommitsPageSize), nil
+}
+
+// flags commits from new authors and commits mentioning a maintainer change.
+// The initial commit is not flagged. For a truncated history we start with the oldest commit we got,
+// we don't know the authors of the commits before
+func flagCommits(commits []gitCommit, truncated bool) []gitCommit {
+	authors := map[string]bool{}
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := &commits[i]
+		if i < len(commits)-1 {
+			c.NewAuthor = !authors[c.Author]
+		}
+		if i < len(commits)-1 || truncated {
+			c.MaintainerChange = maintainerChangeRegex.MatchString(c.Message)
+		}
+		authors[c.Author] = true
+	}
+	return commits
+}
+
+// checks if we only got the latest part of a package's history
+func historyTruncated(source string, commits []gitCommit) bool {
+	if source == "AUR" {
+		return len(commits) >= cgitLogPageSize
+	}
+	return len(commits) >= gitlabCommitsPageSize
+}
+
+// returns the commits of a package's git repository, from cache if possible
+func (ps *UI) getCommits(ctx context.Context, source, base string) ([]gitCommit, error) {
+	key := "log:" + source + "/" + base
+	if cached, found := ps.cachePkgbuild.Get(key); found {
+		if commits, ok := cached.([]gitCommit); ok {
+			return commits, nil
+		}
+	}
+	content, err := getPkgbuildContent(ctx, commitLogUrl(source, base))
+	if err != nil {
+		return nil, err
+	}
+	var commits []gitCommit
+	if source == "AUR" {
+		commits = parseCgitLog(content)
+	} else if commits, err = parseGitlabCommits([]byte(content)); err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, errors.New("no commits found for " + base)
+	}
+	if !ps.conf.DisableCache {
+		ps.cachePkgbuild.Set(key, commits, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+	}
+	return commits, nil
+}
+
+// shows the git history of the selected package
+func (ps *UI) displayHistory() {
+	if ps.selectedPackage == nil {
+		return
+	}
+	pkg := *ps.selectedPackage
+
+	ps.tableHistory.Clear().
+		SetTitle(" [::b]Loading history... ")
+	ps.textHistory.Clear().
+		SetTitle("")
+	ps.flexRight.Clear().
+		AddItem(ps.tableHistory, 0, 1, true).
+		AddItem(ps.textHistory, 0, 2, false)
+	ps.app.SetFocus(ps.tableHistory)
+
+	if !hasRepoFiles(pkg.Source) {
+		ps.tableHistory.SetTitle(" [::b]History - " + pkg.Name + " ")
+		ps.textHistory.SetText("There is no git repository for packages from " + pkg.Source)
+		return
+	}
+
+	ps.jobs.run(jobHistory, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		commits, err := ps.getCommits(ctx, pkg.Source, pkg.PackageBase)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ps.tableHistory.SetTitle(" [::b]Error loading history - " + pkg.Name + " ")
+				ps.textHistory.SetText(err.Error())
+				return
+			}
+			ps.drawHistory(commits, pkg)
+		})
+	})
+}
+
+// draws the list of commits of a package
+func (ps *UI) drawHistory(commits []gitCommit, pkg InfoRecord) {
+	flagged := 0
+	for i, c := range commits {
+		flags := []string{}
+		if c.MaintainerChange {
+			flags = append(flags, "maintainer change")
+		}
+		if c.NewAuthor {
+			flags = append(flags, "new author")
+		}
+		if len(flags) > 0 {
+			flagged++
+		}
+		ps.tableHistory.SetCell(i, 0, &tview.TableCell{
+			Text:            c.Date.Format("2006-01-02"),
+			Color:           ps.conf.Colors().PackagelistHeader,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+			Reference:       c,
+		}).
+			SetCell(i, 1, &tview.TableCell{
+				Text:            tview.Escape(c.Author),
+				Color:           ps.conf.Colors().PackagelistHeader,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+				MaxWidth:        20,
+			}).
+			SetCell(i, 2, &tview.TableCell{
+				Text:            tview.Escape(strings.Join(flags, ", ")),
+				Color:           tcell.ColorRed,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+			}).
+			SetCell(i, 3, &tview.TableCell{
+				Text:            tview.Escape(c.Message),
+				Color:           tcell.ColorWhite,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+				Expansion:       1000,
+			})
+	}
+	title := fmt.Sprintf("%d commits, %d flagged", len(commits), flagged)
+	if historyTruncated(pkg.Source, commits) {
+		title = fmt.Sprintf("latest %d commits, %d flagged, older authors unknown", len(commits), flagged)
+	}
+	ps.tableHistory.SetTitle(fmt.Sprintf(" [::b]%sHistory - %s (%s) ", ps.conf.Glyphs().Package, pkg.Name, title))
+	ps.tableHistory.Select(0, 0).
+		ScrollToBeginning()
+	ps.textHistory.SetText("Press ENTER to show the changes of a commit")
+}
+
+// shows the changes of a commit below the list of commits
+func (ps *UI) displayCommit(c gitCommit) {
+	if ps.selectedPackage == nil {
+		return
+	}
+	pkg := *ps.selectedPackage
+
+	ps.textHistory.Clear().
+		SetTitle(" [::b]Loading commit... ")
+
+	ps.jobs.run(jobHistory, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		key := "commit:" + pkg.Source + "/" + pkg.PackageBase + "/" + c.ID
+		patch, found := "", false
+		if cached, ok := ps.cachePkgbuild.Get(key); ok {
+			patch, found = cached.(string)
+		}
+		var err error
+		if !found {
+			patch, err = getPkgbuildContent(ctx, commitPatchUrl(pkg.Source, pkg.PackageBase, c.ID))
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil && !found && !ps.conf.DisableCache {
+			ps.cachePkgbuild.Set(key, patch, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ps.textHistory.SetTitle(" [::b]Error loading commit ")
+				ps.textHistory.SetText(err.Error())
+				return
+			}
+			ps.textHistory.SetTitle(" [::b]" + tview.Escape(c.Message) + " - " + tview.Escape(c.Author) + " ")
+			ps.textHistory.SetText(colorDiff(patch)).
+				ScrollToBeginning()
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package pacseek
+
+import (
+	"context"
+	"sync"
+)
+
+// job kinds; starting a job cancels a running job of the same kind
+const (
+	jobSearch      = "search"
+	jobInfo        = "info"
+	jobPkgbuild    = "pkgbuild"
+	jobFiles       = "files"
+	jobDeps        = "deps"
+	jobUpgrades    = "upgrades"
+	jobSuggest     = "suggest"
+	jobTransaction = "transaction"
+	jobBuild       = "build"
+	jobLint        = "lint"
+	jobHistory     = "history"
+	jobComments    = "comments"
+	jobWatch       = "watch"
+)
+
+// jobScheduler runs background jobs and cancels superseded ones
+type jobScheduler struct {
+	mut     sync.Mutex
+	running map[string]*job
+}
+
+type job struct {
+	cancel context.CancelFunc
+}
+
+// creates a new job scheduler
+func newJobScheduler() *jobScheduler {
+	return &jobScheduler{
+		running: map[string]*job{},
+	}
+}
+
+// runs a function in the background. A previously started job of the same kind is being cancelled
+func (s *jobScheduler) run(kind string, f func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{cancel: cancel}
+
+	s.mut.Lock()
+	if prev, ok := s.running[kind]; ok {
+		prev.cancel()
+	}
+	s.running[kind] = j
+	s.mut.Unlock()
+
+	go func() {
+		defer func() {
+			s.mut.Lock()
+			if s.running[kind] == j {
+				delete(s.running, kind)
+			}
+			s.mut.Unlock()
+			cancel()
+		}()
+		f(ctx)
+	}()
+}
+
+// cancels a running job of the given kind
+func (s *jobScheduler) cancel(kind string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if j, ok := s.running[kind]; ok {
+		j.cancel()
+		delete(s.running, kind)
+	}
+}
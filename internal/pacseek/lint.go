@@ -0,0 +1,436 @@
+package pacseek
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/moson-mo/pacseek/internal/config"
+	"github.com/rivo/tview"
+)
+
+// severities of PKGBUILD findings
+var lintSeverities = []string{"none", "low", "medium", "high"}
+
+// built-in rules of our PKGBUILD linter
+var pkgbuildRules = []config.PkgbuildRule{
+	{
+		ID:          "curl-pipe-shell",
+		Description: "downloaded script is piped into a shell",
+		Severity:    "high",
+		Pattern:     `\b(curl|wget)\b[^|#]*\|\s*(sudo\s+)?(ba|z|da|k)?sh\b`,
+	},
+	{
+		ID:          "http-source",
+		Description: "source is downloaded over plain http",
+		Severity:    "medium",
+		Pattern:     `http://`,
+		Scope:       `^source(_\w+)?$`,
+	},
+	{
+		ID:          "skip-checksum",
+		Description: "checksum is skipped for a non-VCS source",
+		Severity:    "medium",
+	},
+	{
+		ID:          "write-outside-pkgdir",
+		Description: "writes outside of $pkgdir",
+		Severity:    "high",
+		Pattern: `\b(install|cp|mv|ln)\s[^#;&|]*\s"?(/|~/|\$HOME|\$\{HOME\})[^\s;&|]*"?\s*($|[;&|#])` +
+			`|\b(mkdir|touch|tee|rm|chmod|chown)\s[^#;&|]*\s"?(/|~/|\$HOME|\$\{HOME\})` +
+			`|>>?\s*"?(/|~/|\$HOME|\$\{HOME\})`,
+		Scope:   `^(prepare|pkgver|build|check|package\w*)$`,
+		Exclude: `>>?\s*/dev/(null|stdout|stderr)`,
+	},
+	{
+		ID:          "base64",
+		Description: "contains base64 encoded data",
+		Severity:    "medium",
+		Pattern:     `\bbase64\s+(-\w*d\w*|--decode)\b|[A-Za-z0-9+/]{100,}={0,2}`,
+		Exclude:     `^[^'"]*['"]?[0-9a-fA-F]{32,}['"]?\s*\)?\s*$`,
+	},
+	{
+		ID:          "sudo",
+		Description: "uses sudo",
+		Severity:    "high",
+		Pattern:     `(^|[\s;&|({])sudo\s`,
+		Scope:       `^(prepare|pkgver|build|check|package\w*)$`,
+	},
+	{
+		ID:          "network-in-package",
+		Description: "accesses the network in package()",
+		Severity:    "high",
+		Pattern: `\b(curl|wget|aria2c)\b|\bgit\s+(clone|fetch|pull)\b|\b(npm|yarn|pnpm)\s+(install|i|add)\b` +
+			`|\bpip3?\s+install\b|\bcargo\s+(fetch|install)\b|\bgo\s+(get|mod\s+download)\b`,
+		Scope: `^package\w*$`,
+	},
+	{
+		ID:          "pastebin",
+		Description: "URL points to a pastebin",
+		Severity:    "high",
+		Pattern:     `\b(pastebin\.com|paste\.ee|hastebin\.com|ghostbin\.\w+|termbin\.com|0x0\.st|transfer\.sh|ix\.io|dpaste\.\w+|controlc\.com|rentry\.co|paste\.rs)\b`,
+	},
+}
+
+// built-in checks that can't be expressed with a pattern
+var lintChecks = map[string]func(p *parsedPkgbuild) []int{
+	"skip-checksum": skippedChecksums,
+}
+
+// lintRule is a compiled PKGBUILD rule
+type lintRule struct {
+	config.PkgbuildRule
+	pattern *regexp.Regexp
+	scope   *regexp.Regexp
+	exclude *regexp.Regexp
+	check   func(p *parsedPkgbuild) []int
+}
+
+// lintFinding is a line of a PKGBUILD matching one of our rules
+type lintFinding struct {
+	Line int
+	Rule lintRule
+	Text string
+}
+
+// returns our built-in rules merged with user defined ones.
+// User rules replace built-in rules with the same ID; rules without a pattern are disabled
+func compileLintRules(user []config.PkgbuildRule) ([]lintRule, error) {
+	defs := append([]config.PkgbuildRule{}, pkgbuildRules...)
+	for _, u := range user {
+		replaced := false
+		for i := range defs {
+			if defs[i].ID == u.ID {
+				defs[i] = u
+				replaced = true
+			}
+		}
+		if !replaced {
+			defs = append(defs, u)
+		}
+	}
+
+	rules := []lintRule{}
+	for _, def := range defs {
+		rule := lintRule{PkgbuildRule: def}
+		if def.Pattern == "" {
+			// built-in checks are only used as long as they are not overridden
+			check, ok := lintChecks[def.ID]
+			if !ok || !builtinRule(def) {
+				continue
+			}
+			rule.check = check
+		}
+		if lintSeverity(def.Severity) == 0 {
+			return nil, fmt.Errorf("PKGBUILD rule %q: unknown severity %q (available: low, medium, high)", def.ID, def.Severity)
+		}
+		var err error
+		for _, re := range []struct {
+			expr   string
+			target **regexp.Regexp
+		}{{def.Pattern, &rule.pattern}, {def.Scope, &rule.scope}, {def.Exclude, &rule.exclude}} {
+			if re.expr == "" {
+				continue
+			}
+			if *re.target, err = regexp.Compile(re.expr); err != nil {
+				return nil, fmt.Errorf("PKGBUILD rule %q: %w", def.ID, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// checks if a rule is one of our built-in rules
+func builtinRule(rule config.PkgbuildRule) bool {
+	for _, r := range pkgbuildRules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the rank of a severity; 0 for unknown ones
+func lintSeverity(severity string) int {
+	for i, s := range lintSeverities {
+		if i > 0 && strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return 0
+}
+
+// parsedPkgbuild holds the lines of a PKGBUILD and the function / variable each line belongs to
+type parsedPkgbuild struct {
+	Lines  []string
+	Scopes []string
+	Arrays map[string][]arrayEntry
+}
+
+// arrayEntry is a value of an array in a PKGBUILD
+type arrayEntry struct {
+	Value string
+	Line  int // 1-based
+}
+
+var (
+	reFunctionStart = regexp.MustCompile(`^\s*(function\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*\(\)`)
+	reAssignment    = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\+?=(\()?`)
+	reArrayValue    = regexp.MustCompile(`"[^"]*"|'[^']*'|[^\s()'"]+`)
+)
+
+// splits a PKGBUILD into lines and figures out the function / variable each line belongs to.
+// This is a rough approximation of bash syntax which is good enough for the usual PKGBUILD
+func parsePkgbuild(content string) *parsedPkgbuild {
+	p := &parsedPkgbuild{
+		Lines:  strings.Split(content, "\n"),
+		Arrays: map[string][]arrayEntry{},
+	}
+	scope := ""
+	braces, parens := 0, 0
+	for i, line := range p.Lines {
+		code := stripComment(line)
+		switch {
+		case braces > 0:
+			// within a function
+			braces += strings.Count(code, "{") - strings.Count(code, "}")
+		case parens > 0:
+			// within a multi-line array
+			p.addArrayValues(scope, code, i+1)
+			parens += strings.Count(code, "(") - strings.Count(code, ")")
+		case reFunctionStart.MatchString(code):
+			scope = reFunctionStart.FindStringSubmatch(code)[2]
+			braces = strings.Count(code, "{") - strings.Count(code, "}")
+		case reAssignment.MatchString(code):
+			m := reAssignment.FindStringSubmatch(code)
+			scope = m[1]
+			if m[2] != "" {
+				values := code[strings.Index(code, "(")+1:]
+				parens = 1 + strings.Count(values, "(") - strings.Count(values, ")")
+				p.addArrayValues(scope, values, i+1)
+			}
+		case strings.TrimSpace(code) == "{":
+			// opening brace of a function on its own line
+			braces = 1
+		default:
+			scope = ""
+		}
+		p.Scopes = append(p.Scopes, scope)
+	}
+	return p
+}
+
+// adds the values of an array found on a line
+func (p *parsedPkgbuild) addArrayValues(name, code string, line int) {
+	if i := strings.LastIndex(code, ")"); i >= 0 && strings.Count(code, "(") < strings.Count(code, ")") {
+		code = code[:i]
+	}
+	for _, v := range reArrayValue.FindAllString(code, -1) {
+		p.Arrays[name] = append(p.Arrays[name], arrayEntry{Value: strings.Trim(v, `"'`), Line: line})
+	}
+}
+
+// removes a trailing comment from a line of code
+func stripComment(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return ""
+	}
+	if i := strings.Index(line, " #"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// returns the lines with "SKIP" checksums for sources that are not a VCS repository
+func skippedChecksums(p *parsedPkgbuild) []int {
+	lines := []int{}
+	for name, sums := range p.Arrays {
+		algo, arch, ok := strings.Cut(name, "sums")
+		if !ok || algo == "" || (arch != "" && !strings.HasPrefix(arch, "_")) {
+			continue
+		}
+		sources := p.Arrays["source"+arch]
+		for i, sum := range sums {
+			if sum.Value != "SKIP" || i >= len(sources) {
+				continue
+			}
+			source := sources[i].Value
+			if _, url, ok := strings.Cut(source, "::"); ok {
+				source = url
+			}
+			vcs := false
+			for _, prefix := range []string{"git+", "git://", "svn+", "svn://", "hg+", "bzr+", "bzr://", "fossil+"} {
+				vcs = vcs || strings.HasPrefix(source, prefix)
+			}
+			// local files don't need checksums either
+			if !vcs && strings.Contains(source, "://") {
+				lines = append(lines, sum.Line)
+			}
+		}
+	}
+	return lines
+}
+
+// runs our rules against a PKGBUILD and returns the findings ordered by line
+func lintPkgbuild(content string, rules []lintRule) []lintFinding {
+	p := parsePkgbuild(content)
+	findings := []lintFinding{}
+	add := func(line int, rule lintRule) {
+		findings = append(findings, lintFinding{
+			Line: line,
+			Rule: rule,
+			Text: strings.TrimSpace(p.Lines[line-1]),
+		})
+	}
+
+	for _, rule := range rules {
+		if rule.check != nil {
+			for _, line := range rule.check(p) {
+				add(line, rule)
+			}
+			continue
+		}
+		for i, line := range p.Lines {
+			code := stripComment(line)
+			if code == "" ||
+				(rule.scope != nil && !rule.scope.MatchString(p.Scopes[i])) ||
+				(rule.exclude != nil && rule.exclude.MatchString(code)) {
+				continue
+			}
+			if rule.pattern.MatchString(code) {
+				add(i+1, rule)
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// returns the highest severity of a list of findings
+func lintRisk(findings []lintFinding) string {
+	risk := 0
+	for _, f := range findings {
+		risk = max(risk, lintSeverity(f.Rule.Severity))
+	}
+	return lintSeverities[risk]
+}
+
+// returns the color for a severity
+func (ps *UI) lintColor(severity string) tcell.Color {
+	switch lintSeverity(severity) {
+	case 0:
+		return tcell.ColorGreen
+	case 1:
+		return ps.conf.Colors().PackagelistHeader
+	case 2:
+		return tcell.ColorYellow
+	}
+	return tcell.ColorRed
+}
+
+// composes our risk badge, e.g. "PKGBUILD risk: high (3 findings)"
+func (ps *UI) lintBadge(findings []lintFinding) string {
+	risk := lintRisk(findings)
+	text := fmt.Sprintf(" [%s::b]PKGBUILD risk: %s[-::-]", ps.lintColor(risk).String(), risk)
+	if len(findings) > 0 {
+		text += fmt.Sprintf(" (%d finding(s), see PKGBUILD)", len(findings))
+	}
+	return text
+}
+
+// checks the PKGBUILD of an AUR package in the background and shows our risk badge in a row of the package details
+func (ps *UI) lintDetails(pkg InfoRecord, row int) {
+	ps.tableDetails.SetCell(row, 1, &tview.TableCell{
+		Text:            " Checking PKGBUILD...",
+		Color:           ps.conf.Colors().PackagelistHeader,
+		BackgroundColor: ps.conf.Colors().DefaultBackground,
+	})
+
+	ps.jobs.run(jobLint, func(ctx context.Context) {
+		content, found := ps.cachePkgbuild.Get(pkg.PackageBase)
+		if !found {
+			// don't download PKGBUILD's while scrolling through the list
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(ps.conf.AurSearchDelay) * time.Millisecond):
+			}
+			c, err := getPkgbuildContent(ctx, ps.pkgbuildUrl(pkg.Source, pkg.PackageBase))
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				ps.app.QueueUpdateDraw(func() {
+					if ps.selectedPackage != nil && ps.selectedPackage.Name == pkg.Name && ps.flexRight.GetItem(0) == ps.tableDetails {
+						ps.tableDetails.GetCell(row, 1).SetText(" PKGBUILD could not be checked: " + tview.Escape(err.Error()))
+					}
+				})
+				return
+			}
+			if !ps.conf.DisableCache {
+				ps.cachePkgbuild.Set(pkg.PackageBase, c, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+			}
+			content = c
+		}
+		findings := lintPkgbuild(content.(string), ps.lintRules)
+		if ctx.Err() != nil {
+			return
+		}
+
+		ps.app.QueueUpdateDraw(func() {
+			if ps.selectedPackage == nil || ps.selectedPackage.Name != pkg.Name || ps.flexRight.GetItem(0) != ps.tableDetails {
+				return
+			}
+			ps.tableDetails.GetCell(row, 1).SetText(ps.lintBadge(findings))
+		})
+	})
+}
+
+// draws the findings of our linter below the PKGBUILD. offset is the number of lines shown above the PKGBUILD
+func (ps *UI) drawPkgbuildFindings(findings []lintFinding, offset int) {
+	ps.tableFindings.Clear().
+		SetTitle(" [::b]" + ps.conf.Glyphs().Pkgbuild + strings.TrimSpace(ps.lintBadge(findings)) + " ")
+	for i, f := range findings {
+		ps.tableFindings.SetCell(i, 0, &tview.TableCell{
+			Text:            fmt.Sprintf("%4d", f.Line),
+			Color:           ps.conf.Colors().PackagelistHeader,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+			Reference:       offset + f.Line - 1,
+		}).
+			SetCell(i, 1, &tview.TableCell{
+				Text:            f.Rule.Severity,
+				Color:           ps.lintColor(f.Rule.Severity),
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+			}).
+			SetCell(i, 2, &tview.TableCell{
+				Text:            tview.Escape(f.Rule.Description),
+				Color:           tcell.ColorWhite,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+			}).
+			SetCell(i, 3, &tview.TableCell{
+				Text:            tview.Escape(f.Text),
+				Color:           ps.conf.Colors().PackagelistHeader,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+				Expansion:       1000,
+			})
+	}
+	if len(findings) == 0 {
+		ps.tableFindings.SetCell(0, 0, &tview.TableCell{
+			Text:            "No findings",
+			Color:           ps.conf.Colors().PackagelistHeader,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+		})
+	}
+	ps.tableFindings.Select(0, 0).
+		ScrollToBeginning()
+
+	height := min(max(len(findings), 1), 8) + 2
+	ps.flexRight.AddItem(ps.tableFindings, height, 0, false)
+}
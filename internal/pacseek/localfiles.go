@@ -0,0 +1,163 @@
+package pacseek
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+	"github.com/rivo/tview"
+)
+
+// packageFile is a file of an installed package and its state on disk
+type packageFile struct {
+	Path       string
+	Backup     bool // listed in the backup array of the package
+	Modified   bool // backup file differs from the installed version
+	Missing    bool
+	Unreadable bool // we could not check a backup file (e.g. permission denied)
+}
+
+// displays the files of the selected package
+func (ps *UI) displayFiles() {
+	if ps.selectedPackage == nil {
+		return
+	}
+	pkg := *ps.selectedPackage
+
+	ps.textFiles.Clear().
+		SetTitle(" [::b]Loading files... ")
+	ps.flexRight.Clear().
+		AddItem(ps.textFiles, 0, 1, true)
+	ps.app.SetFocus(ps.textFiles)
+
+	ps.jobs.run(jobFiles, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		files, err := ps.getPackageFiles(ctx, pkg.Name)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ps.textFiles.SetTitle(" [::b]Error loading files - " + pkg.Name + " ")
+				ps.textFiles.SetText(err.Error())
+				return
+			}
+			ps.drawFiles(files, pkg.Name)
+		})
+	})
+}
+
+// returns the files of an installed package and checks them on disk
+func (ps *UI) getPackageFiles(ctx context.Context, name string) ([]packageFile, error) {
+	ps.locker.Lock()
+	local, err := ps.alpmHandle.LocalDB()
+	if err != nil {
+		ps.locker.Unlock()
+		return nil, err
+	}
+	p := local.Pkg(name)
+	if p == nil {
+		ps.locker.Unlock()
+		return nil, errors.New(name + " is not installed")
+	}
+	files := p.Files()
+	backups := p.Backup().Slice()
+	ps.locker.Unlock()
+
+	return checkPackageFiles(ctx, "/", files, backups), nil
+}
+
+// checks if the files of a package exist on disk and if backup files have been modified
+func checkPackageFiles(ctx context.Context, root string, files []alpm.File, backups []alpm.BackupFile) []packageFile {
+	hashes := map[string]string{}
+	for _, b := range backups {
+		hashes[b.Name] = b.Hash
+	}
+
+	ret := []packageFile{}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		file := packageFile{
+			Path: "/" + f.Name,
+		}
+		full := path.Join(root, f.Name)
+		if _, err := os.Lstat(full); errors.Is(err, fs.ErrNotExist) {
+			file.Missing = true
+		}
+
+		if hash, ok := hashes[f.Name]; ok {
+			file.Backup = true
+			if !file.Missing {
+				sum, err := md5File(full)
+				if err != nil {
+					file.Unreadable = true
+				} else {
+					file.Modified = sum != hash
+				}
+			}
+		}
+		ret = append(ret, file)
+	}
+	return ret
+}
+
+// calculates the md5 checksum of a file
+func md5File(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// draws the file list
+func (ps *UI) drawFiles(files []packageFile, pkg string) {
+	missing, modified := 0, 0
+	lines := []string{}
+	for _, f := range files {
+		line := tview.Escape(f.Path)
+		notes := []string{}
+		if f.Backup {
+			notes = append(notes, "backup")
+		}
+		switch {
+		case f.Missing:
+			missing++
+			notes = append(notes, "[red]missing[-]")
+		case f.Modified:
+			modified++
+			notes = append(notes, "[yellow]modified[-]")
+		case f.Unreadable:
+			notes = append(notes, "unreadable")
+		}
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, ", ") + ")"
+		}
+		if f.Backup || f.Missing {
+			line = "[::b]" + line + "[::-]"
+		}
+		lines = append(lines, line)
+	}
+
+	ps.textFiles.SetTitle(fmt.Sprintf(" [::b]Files - %s (%d files, %d missing, %d modified) ", pkg, len(files), missing, modified))
+	ps.textFiles.SetText(strings.Join(lines, "\n")).
+		ScrollToBeginning()
+}
@@ -0,0 +1,180 @@
+package pacseek
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Jguer/go-alpm/v2"
+)
+
+// orphanCandidate is an installed package with the information we need to find orphans
+type orphanCandidate struct {
+	AsDependency bool     // installed as a dependency
+	RequiredBy   []string // installed packages requiring it
+	Size         int64    // installed size
+}
+
+// returns packages that were installed as dependencies but are not required anymore.
+// Packages only required by other orphans are orphans as well (like "pacman -Qdtt" in a loop)
+func findOrphans(candidates map[string]orphanCandidate) ([]string, int64) {
+	orphans := map[string]bool{}
+	for changed := true; changed; {
+		changed = false
+		for name, c := range candidates {
+			if !c.AsDependency || orphans[name] {
+				continue
+			}
+			required := false
+			for _, r := range c.RequiredBy {
+				if !orphans[r] {
+					required = true
+					break
+				}
+			}
+			if !required {
+				orphans[name] = true
+				changed = true
+			}
+		}
+	}
+
+	names := []string{}
+	size := int64(0)
+	for name := range orphans {
+		names = append(names, name)
+		size += candidates[name].Size
+	}
+	sort.Strings(names)
+	return names, size
+}
+
+// returns all installed packages with the information we need to find orphans
+func localOrphanCandidates(h *alpm.Handle) (map[string]orphanCandidate, error) {
+	local, err := h.LocalDB()
+	if err != nil {
+		return nil, err
+	}
+	candidates := map[string]orphanCandidate{}
+	for _, pkg := range local.PkgCache().Slice() {
+		candidates[pkg.Name()] = orphanCandidate{
+			AsDependency: pkg.Reason() == alpm.PkgReasonDepend,
+			RequiredBy:   pkg.ComputeRequiredBy(),
+			Size:         pkg.ISize(),
+		}
+	}
+	return candidates, nil
+}
+
+// returns orphaned packages and their total installed size
+func getOrphans(h *alpm.Handle) ([]string, int64, error) {
+	candidates, err := localOrphanCandidates(h)
+	if err != nil {
+		return nil, 0, err
+	}
+	orphans, size := findOrphans(candidates)
+	return orphans, size, nil
+}
+
+// displays packages that are not needed anymore
+func (ps *UI) displayOrphans() {
+	ps.orphansShown = false
+	ps.tablePackages.Clear().
+		SetCellSimple(0, 0, "Generating list, please wait...")
+
+	ps.jobs.run(jobSearch, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		ps.locker.Lock()
+		names, size, err := getOrphans(ps.alpmHandle)
+		var orphans []InfoRecord
+		if err == nil {
+			orphans = infoPacman(ps.alpmHandle, ps.conf.ComputeRequiredBy, names...).Results
+			addLocalSatisfiers(ps.alpmHandle, orphans...)
+		}
+		ps.locker.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			ps.app.QueueUpdateDraw(func() {
+				ps.displayMessage(err.Error(), true)
+			})
+			return
+		}
+
+		packages := []Package{}
+		for _, pkg := range orphans {
+			packages = append(packages, Package{
+				Name:         pkg.Name,
+				Source:       pkg.Source,
+				IsInstalled:  true,
+				LastModified: pkg.LastModified,
+				Popularity:   math.MaxFloat64,
+				Size:         pkg.LocalSize,
+				Version:      pkg.Version,
+				LocalVersion: pkg.LocalVersion,
+				Maintainer:   pkg.Maintainer,
+			})
+			if !ps.conf.DisableCache {
+				ps.cacheInfo.Set(pkg.Name+"-"+pkg.Source, pkg, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+			}
+		}
+		ps.shownPackages = packages
+		ps.app.QueueUpdateDraw(func() {
+			ps.drawPackageListContent(packages, ps.conf.PackageColumnWidth)
+			ps.tablePackages.Select(1, 0)
+			ps.orphansShown = true
+			if len(packages) == 0 {
+				ps.displayMessage("No orphaned packages found", false)
+				return
+			}
+			ps.displayMessage(fmt.Sprintf("%d orphaned packages (%s). CTRL+R: Remove all", len(packages), formatSize(size)), false)
+		})
+	})
+}
+
+// removes the orphaned packages shown in our package list with our uninstall command
+func (ps *UI) removeOrphans() {
+	if !ps.orphansShown {
+		return
+	}
+	if len(ps.shownPackages) == 0 {
+		ps.displayMessage("No orphaned packages found", false)
+		return
+	}
+
+	pkgs := []InfoRecord{}
+	for _, p := range ps.shownPackages {
+		pkgs = append(pkgs, InfoRecord{Name: p.Name, PackageBase: p.Name, Source: "local"})
+	}
+	ps.previewTransaction(nil, pkgs, func() {
+		ps.runCommand(ps.shell, "-c", composeCommand(ps.conf.UninstallCommand, pkgs...))
+		ps.displayOrphans()
+	})
+}
+
+// formats a size in bytes as a human readable string
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// formats a size difference with its sign, e.g. "+1.5 KiB"
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatSize(-delta)
+	}
+	return "+" + formatSize(delta)
+}
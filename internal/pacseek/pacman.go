@@ -1,6 +1,7 @@
 package pacseek
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"math"
@@ -8,13 +9,17 @@ import (
 	"os/exec"
 	"path"
 	"strconv"
-	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Jguer/go-alpm/v2"
 	pconf "github.com/Morganamilo/go-pacmanconf"
 	"github.com/moson-mo/pacseek/internal/util"
 )
 
+// note that is being added to the description of packages that are only installed locally
+const localOnlyNote = "\n[red]* Package not found in repositories/AUR *"
+
 // creates the alpm handler used to search packages
 func initPacmanDbs(dbPath, confPath string, repos []string) (*alpm.Handle, error) {
 	h, err := alpm.Initialize("/", dbPath)
@@ -22,23 +27,31 @@ func initPacmanDbs(dbPath, confPath string, repos []string) (*alpm.Handle, error
 		return nil, err
 	}
 
+	if err = registerSyncDbs(h, confPath, repos); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// registers the repositories from our pacman.conf
+func registerSyncDbs(h *alpm.Handle, confPath string, repos []string) error {
 	conf, _, err := pconf.ParseFile(confPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	for _, repo := range conf.Repos {
 		if (len(repos) > 0 && util.SliceContains(repos, repo.Name)) || len(repos) == 0 {
 			_, err := h.RegisterSyncDB(repo.Name, 0)
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
 	h.SetIgnorePkgs(conf.IgnorePkg)
 	h.SetIgnoreGroups(conf.IgnoreGroup)
 
-	return h, nil
+	return nil
 }
 
 // searches the pacman databases and returns packages that could be found (starting with "term")
@@ -58,6 +71,19 @@ func searchRepos(h *alpm.Handle, term string, mode string, by string, maxResults
 		return packages, installed, err
 	}
 
+	q, err := parseSearchQuery(term)
+	if err != nil {
+		return packages, installed, err
+	}
+	q.applySearchBy(by)
+	if !q.allowsSource(false) {
+		return packages, installed, nil
+	}
+	match, err := newPackageMatcher(q.Term, mode, by)
+	if err != nil {
+		return packages, installed, err
+	}
+
 	searchDbs := append(dbs.Slice(), local)
 
 	counter := 0
@@ -66,28 +92,36 @@ func searchRepos(h *alpm.Handle, term string, mode string, by string, maxResults
 			if counter >= maxResults {
 				break
 			}
-			compFunc := strings.HasPrefix
-			if mode == "Contains" {
-				compFunc = strings.Contains
+			if q.Term != "" && !match.matches(pkg.Name(), pkg.Description()) {
+				continue
+			}
+			if len(q.Filters) > 0 && !q.matches(alpmInfoRecord(pkg, db.Name())) {
+				continue
 			}
 
-			if compFunc(pkg.Name(), term) ||
-				(by == "Name & Description" && compFunc(strings.ToLower(pkg.Description()), term)) {
-				pkg := Package{
-					Name:         pkg.Name(),
-					Source:       db.Name(),
-					IsInstalled:  local.Pkg(pkg.Name()) != nil,
-					LastModified: int(pkg.BuildDate().Unix()),
-					Popularity:   math.MaxFloat64,
-				}
-				if db != local {
-					packages = append(packages, pkg)
-				} else {
-					installed = append(installed, pkg)
-				}
-
-				counter++
+			p := Package{
+				Name:         pkg.Name(),
+				Source:       db.Name(),
+				LastModified: int(pkg.BuildDate().Unix()),
+				Popularity:   math.MaxFloat64,
+				Size:         pkg.ISize(),
+				Version:      pkg.Version(),
+				Maintainer:   pkg.Packager(),
+			}
+			if lpkg := local.Pkg(pkg.Name()); lpkg != nil {
+				p.IsInstalled = true
+				p.LocalVersion = lpkg.Version()
+			}
+			if !q.matchesPackage(p) {
+				continue
+			}
+			if db != local {
+				packages = append(packages, p)
+			} else {
+				installed = append(installed, p)
 			}
+
+			counter++
 		}
 	}
 	return packages, installed, nil
@@ -106,13 +140,76 @@ func suggestRepos(h *alpm.Handle, term string) []string {
 
 // create/update temporary sync DB
 func syncToTempDB(confPath string, repos []string) (*alpm.Handle, error) {
+	tmpdb, err := prepareTempDB(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// execute pacman and sync to temporary db
+	cmd := exec.Command("fakeroot", "--", "pacman", "-Sy", "--disable-sandbox-filesystem", "--dbpath="+tmpdb)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.New(string(out))
+	}
+
+	h, err := initPacmanDbs(tmpdb, confPath, repos)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// syncs the files databases into our temporary DB path and returns an alpm handle using them.
+// A cancelled context interrupts the sync
+func syncFilesToTempDB(ctx context.Context, confPath string, repos []string) (*alpm.Handle, error) {
+	tmpdb, err := prepareTempDB(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// execute pacman and sync the files databases to temporary db
+	cmd := exec.CommandContext(ctx, "fakeroot", "--", "pacman", "-Fy", "--disable-sandbox-filesystem", "--dbpath="+tmpdb)
+	// fakeroot does not forward signals; we interrupt the whole process group, so that pacman can release its lock
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, errors.New(string(out))
+	}
+
+	h, err := alpm.Initialize("/", tmpdb)
+	if err != nil {
+		return nil, err
+	}
+	// the extension needs to be set before registering our DB's
+	if err = h.SetDBExt(".files"); err != nil {
+		h.Release()
+		return nil, err
+	}
+	if err = registerSyncDbs(h, confPath, repos); err != nil {
+		h.Release()
+		return nil, err
+	}
+	return h, nil
+}
+
+// creates our temporary DB path (if needed) and returns it
+func prepareTempDB(confPath string) (string, error) {
 	// check if fakeroot is installed
 	if _, err := os.Stat("/usr/bin/fakeroot"); errors.Is(err, fs.ErrNotExist) {
-		return nil, errors.New("fakeroot not installed")
+		return "", errors.New("fakeroot not installed")
 	}
 	conf, _, err := pconf.ParseFile(confPath)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	/*
 		We use the same naming as "checkupdates" to have less data to transfer
@@ -125,29 +222,16 @@ func syncToTempDB(confPath string, repos []string) (*alpm.Handle, error) {
 	if _, err := os.Stat(tmpdb); errors.Is(err, fs.ErrNotExist) {
 		err := os.MkdirAll(tmpdb, 0755)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 	}
 	if _, err := os.Stat(local); errors.Is(err, fs.ErrNotExist) {
 		err := os.Symlink(path.Join(conf.DBPath, "local"), local)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 	}
-
-	// execute pacman and sync to temporary db
-	cmd := exec.Command("fakeroot", "--", "pacman", "-Sy", "--disable-sandbox-filesystem", "--dbpath="+tmpdb)
-
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, errors.New(string(out))
-	}
-
-	h, err := initPacmanDbs(tmpdb, confPath, repos)
-	if err != nil {
-		return nil, err
-	}
-	return h, nil
+	return tmpdb, nil
 }
 
 // returns packages that can be upgraded & packages that only exist locally
@@ -261,51 +345,7 @@ func infoPacman(h *alpm.Handle, computeRequiredBy bool, pkgs ...string) SearchRe
 				continue
 			}
 
-			deps := []string{}
-			makedeps := []string{}
-			odeps := []string{}
-			cdeps := []string{}
-			prov := []string{}
-			conf := []string{}
-
-			for _, d := range p.Depends().Slice() {
-				deps = append(deps, d.String())
-			}
-			for _, d := range p.MakeDepends().Slice() {
-				makedeps = append(makedeps, d.String())
-			}
-			for _, d := range p.OptionalDepends().Slice() {
-				odeps = append(odeps, d.String())
-			}
-			for _, d := range p.CheckDepends().Slice() {
-				cdeps = append(cdeps, d.String())
-			}
-			for _, pr := range p.Provides().Slice() {
-				prov = append(prov, pr.String())
-			}
-			for _, c := range p.Conflicts().Slice() {
-				conf = append(conf, c.String())
-			}
-
-			i := InfoRecord{
-				Name:         p.Name(),
-				Description:  p.Description(),
-				Provides:     prov,
-				Conflicts:    conf,
-				Version:      p.Version(),
-				License:      p.Licenses().Slice(),
-				Maintainer:   p.Packager(),
-				Depends:      deps,
-				MakeDepends:  makedeps,
-				OptDepends:   odeps,
-				CheckDepends: cdeps,
-				URL:          p.URL(),
-				LastModified: int(p.BuildDate().UTC().Unix()),
-				Source:       db.Name(),
-				Architecture: p.Architecture(),
-				PackageBase:  p.Base(),
-				IsIgnored:    p.ShouldIgnore(),
-			}
+			i := alpmInfoRecord(p, db.Name())
 
 			if computeRequiredBy {
 				optFor := p.ComputeOptionalFor()
@@ -314,11 +354,9 @@ func infoPacman(h *alpm.Handle, computeRequiredBy bool, pkgs ...string) SearchRe
 				}
 				i.RequiredBy = append(p.ComputeRequiredBy(), optFor...)
 			}
-			if lpkg := local.Pkg(p.Name()); lpkg != nil {
-				i.LocalVersion = lpkg.Version()
-			}
+			setInstallState(&i, local.Pkg(p.Name()))
 			if db.Name() == "local" {
-				i.Description = p.Description() + "\n[red]* Package not found in repositories/AUR *"
+				i.Description = p.Description() + localOnlyNote
 			}
 
 			r.Results = append(r.Results, i)
@@ -332,7 +370,13 @@ func infoPacman(h *alpm.Handle, computeRequiredBy bool, pkgs ...string) SearchRe
 
 // add locally installed satisfiers to pacakge info records
 func addLocalSatisfiers(h *alpm.Handle, pkgs ...InfoRecord) {
-	local, err := h.LocalDB()
+	// without a handle we can't tell what's installed
+	var local alpm.IDB
+	if h != nil {
+		if db, err := h.LocalDB(); err == nil {
+			local = db
+		}
+	}
 
 	for i := 0; i < len(pkgs); i++ {
 		depList := []struct {
@@ -353,7 +397,7 @@ func addLocalSatisfiers(h *alpm.Handle, pkgs ...InfoRecord) {
 					DepType:   entry.deptype,
 					Installed: false,
 				}
-				if err == nil {
+				if local != nil {
 					found, _ := local.PkgCache().FindSatisfier(dep)
 					if found != nil {
 						sat.Satisfier = found.Name()
@@ -366,3 +410,107 @@ func addLocalSatisfiers(h *alpm.Handle, pkgs ...InfoRecord) {
 		pkgs[i].DepsAndSatisfiers = satisfiers
 	}
 }
+
+// converts an alpm package to an InfoRecord
+func alpmInfoRecord(p alpm.IPackage, source string) InfoRecord {
+	deps := []string{}
+	makedeps := []string{}
+	odeps := []string{}
+	cdeps := []string{}
+	prov := []string{}
+	conf := []string{}
+
+	for _, d := range p.Depends().Slice() {
+		deps = append(deps, d.String())
+	}
+	for _, d := range p.MakeDepends().Slice() {
+		makedeps = append(makedeps, d.String())
+	}
+	for _, d := range p.OptionalDepends().Slice() {
+		odeps = append(odeps, d.String())
+	}
+	for _, d := range p.CheckDepends().Slice() {
+		cdeps = append(cdeps, d.String())
+	}
+	for _, pr := range p.Provides().Slice() {
+		prov = append(prov, pr.String())
+	}
+	for _, c := range p.Conflicts().Slice() {
+		conf = append(conf, c.String())
+	}
+
+	return InfoRecord{
+		Name:          p.Name(),
+		Description:   p.Description(),
+		Provides:      prov,
+		Conflicts:     conf,
+		Version:       p.Version(),
+		License:       p.Licenses().Slice(),
+		Maintainer:    p.Packager(),
+		Depends:       deps,
+		MakeDepends:   makedeps,
+		OptDepends:    odeps,
+		CheckDepends:  cdeps,
+		URL:           p.URL(),
+		LastModified:  int(p.BuildDate().UTC().Unix()),
+		Source:        source,
+		Architecture:  p.Architecture(),
+		PackageBase:   p.Base(),
+		IsIgnored:     p.ShouldIgnore(),
+		DownloadSize:  p.Size(),
+		InstalledSize: p.ISize(),
+	}
+}
+
+// sets install state and local version of the packages in our list
+func addPackageInstallState(h *alpm.Handle, pkgs []Package) {
+	if h == nil {
+		return
+	}
+	local, err := h.LocalDB()
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(pkgs); i++ {
+		pkgs[i].IsInstalled = false
+		pkgs[i].LocalVersion = ""
+		if lpkg := local.Pkg(pkgs[i].Name); lpkg != nil {
+			pkgs[i].IsInstalled = true
+			pkgs[i].LocalVersion = lpkg.Version()
+		}
+	}
+}
+
+// sets local version, install reason, date and size for packages that are installed
+func addInstallState(h *alpm.Handle, pkgs ...InfoRecord) {
+	if h == nil {
+		return
+	}
+	local, err := h.LocalDB()
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(pkgs); i++ {
+		setInstallState(&pkgs[i], local.Pkg(pkgs[i].Name))
+	}
+}
+
+// sets local version, install reason, dates and size from a local package (or resets them if it's nil)
+func setInstallState(i *InfoRecord, lpkg alpm.IPackage) {
+	if lpkg == nil {
+		i.LocalVersion = ""
+		i.InstallReason = ""
+		i.InstallDate = 0
+		i.LocalBuildDate = 0
+		i.LocalSize = 0
+		return
+	}
+	i.LocalVersion = lpkg.Version()
+	i.InstallReason = "Explicit"
+	if lpkg.Reason() == alpm.PkgReasonDepend {
+		i.InstallReason = "Dependency"
+	}
+	i.InstallDate = int(lpkg.InstallDate().UTC().Unix())
+	i.LocalBuildDate = int(lpkg.BuildDate().UTC().Unix())
+	i.LocalSize = lpkg.ISize()
+}
@@ -1,9 +1,25 @@
 package pacseek
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Jguer/go-alpm/v2"
+	"github.com/moson-mo/pacseek/internal/args"
+	"github.com/moson-mo/pacseek/internal/config"
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/patrickmn/go-cache"
+	"github.com/rivo/tview"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -95,41 +111,1188 @@ func (suite *pacseekTestSuite) TestIsInstalled() {
 
 func (suite *pacseekTestSuite) TestSearchAur() {
 	// ok
-	p, err := searchAur("http://server.moson.rocks:10666/rpc", "yay", 5000, "StartsWith", "Name", 20)
+	p, err := searchAur(context.Background(), "http://server.moson.rocks:10666/rpc", "yay", 5000, "StartsWith", "Name", 20)
 	suite.Nil(err, err)
 	suite.Greater(len(p), 0, "no results for yay")
-	p, err = searchAur("http://server.moson.rocks:10666/rpc", "yay", 5000, "Contains", "Name", 20)
+	p, err = searchAur(context.Background(), "http://server.moson.rocks:10666/rpc", "yay", 5000, "Contains", "Name", 20)
 	suite.Nil(err, err)
 	suite.Greater(len(p), 0, "no results for yay")
-	p, err = searchAur("http://server.moson.rocks:10666/rpc", "yay", 5000, "Contains", "Name & Description", 20)
+	p, err = searchAur(context.Background(), "http://server.moson.rocks:10666/rpc", "yay", 5000, "Contains", "Name & Description", 20)
 	suite.Nil(err, err)
 	suite.Greater(len(p), 0, "no results for yay")
-	p, err = searchAur("http://server.moson.rocks:10666/rpc", "yay", 5000, "StartsWith", "Name & Description", 20)
+	p, err = searchAur(context.Background(), "http://server.moson.rocks:10666/rpc", "yay", 5000, "StartsWith", "Name & Description", 20)
 	suite.Nil(err, err)
 	suite.Greater(len(p), 0, "no results for yay")
 
 	// nok
-	p, err = searchAur("http://server.moson.rocks:10666/rpcbla", "yay", 5000, "StartsWith", "Name", 20)
+	p, err = searchAur(context.Background(), "http://server.moson.rocks:10666/rpcbla", "yay", 5000, "StartsWith", "Name", 20)
 	suite.NotNil(err, err)
 	suite.Equal([]Package{}, p, "[]Packages not empty")
 
-	p, err = searchAur("nonsense", "yay", 5000, "StartsWith", "Name", 20)
+	p, err = searchAur(context.Background(), "nonsense", "yay", 5000, "StartsWith", "Name", 20)
 	suite.NotNil(err, err)
 	suite.Equal([]Package{}, p, "[]Packages not empty")
 }
 
 func (suite *pacseekTestSuite) TestInfoAur() {
 	// ok
-	p := infoAur("http://server.moson.rocks:10666/rpc", 5000, "yay")
+	p := infoAur(context.Background(), "http://server.moson.rocks:10666/rpc", 5000, "yay")
 	suite.Equal("", p.Error, "error not empty")
 	suite.Greater(len(p.Results), 0, "no results for yay")
 
 	// nok
-	p = infoAur("http://server.moson.rocks:10666/rpcnonsense", 5000, "yay")
+	p = infoAur(context.Background(), "http://server.moson.rocks:10666/rpcnonsense", 5000, "yay")
 	suite.NotEqual("", p.Error, "error empty")
 	suite.Equal(0, len(p.Results), "Results not empty")
 
-	p = infoAur("nonsense", 5000, "yay")
+	p = infoAur(context.Background(), "nonsense", 5000, "yay")
 	suite.NotEqual("", p.Error, "error empty")
 	suite.Equal(0, len(p.Results), "Results not empty")
 }
+
+type testSource struct {
+	name     string
+	packages []Package
+	infos    []InfoRecord
+	err      error
+}
+
+func (s *testSource) Name() string               { return s.name }
+func (s *testSource) Handles(source string) bool { return source == s.name }
+func (s *testSource) Search(ctx context.Context, term string) ([]Package, error) {
+	return s.packages, s.err
+}
+func (s *testSource) Info(ctx context.Context, pkgs ...string) SearchResults {
+	sr := SearchResults{Results: []InfoRecord{}}
+	if s.err != nil {
+		sr.Error = s.err.Error()
+	}
+	for _, info := range s.infos {
+		if util.SliceContains(pkgs, info.Name) {
+			sr.Results = append(sr.Results, info)
+		}
+	}
+	return sr
+}
+func (s *testSource) Suggest(ctx context.Context, term string) []string      { return []string{term} }
+func (s *testSource) PkgbuildUrl(source, base string) string {
+	return s.name + "/" + base
+}
+
+func (suite *pacseekTestSuite) TestSearchSources() {
+	ps := UI{
+		sources: []PackageSource{
+			&testSource{name: "one", packages: []Package{{Name: "a", Source: "one"}, {Name: "b", Source: "local"}, {Name: "c", Source: "local"}}},
+			&testSource{name: "two", packages: []Package{{Name: "b", Source: "two"}}},
+		},
+	}
+
+	p, errs := ps.searchSources(context.Background(), "x")
+	suite.Len(errs, 0)
+	suite.Equal([]Package{{Name: "a", Source: "one"}, {Name: "b", Source: "two"}, {Name: "c", Source: "local"}}, p)
+
+	suite.Equal("two", ps.sourceFor("two").Name())
+	suite.Nil(ps.sourceFor("three"))
+	suite.Equal("one/x", ps.pkgbuildUrl("one", "x"))
+}
+
+func (suite *pacseekTestSuite) TestQuery() {
+	file := path.Join(suite.T().TempDir(), "packages-meta-ext-v1.json")
+	b, _ := json.Marshal([]InfoRecord{
+		{Name: "yay", Description: "Yet another yogurt", Version: "12.0.0-1"},
+		{Name: "yay-bin", Description: "Yet another yogurt", Version: "12.0.0-1"},
+		{Name: "paru", Description: "Feature packed AUR helper", Version: "2.0.0-1", Depends: []string{"git"}},
+		{Name: "ayay", Description: "Not so yogurt", Version: "1.0.0-1"},
+	})
+	suite.Nil(os.WriteFile(file, b, 0644))
+
+	conf := config.Defaults()
+	conf.PackageSources = []string{"AUR"}
+	conf.AurDataSource = aurSourceDump
+	conf.AurDumpUrl = "file://" + file
+	conf.SearchMode = "StartsWith"
+	ps := &UI{
+		conf:        conf,
+		locker:      &sync.RWMutex{},
+		dumpLocker:  &sync.Mutex{},
+		filesLocker: &sync.Mutex{},
+	}
+	suite.Nil(ps.initSources())
+
+	query := func(command string, commandArgs ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := ps.query(args.Flags{Json: true, Command: command, CommandArgs: commandArgs}, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	// search
+	code, out, errOut := query("search", "yay")
+	suite.Equal(QueryFound, code)
+	suite.Empty(errOut)
+	packages := []Package{}
+	suite.Nil(json.Unmarshal([]byte(out), &packages))
+	suite.Equal([]Package{{Name: "yay", Source: "AUR", Version: "12.0.0-1"}, {Name: "yay-bin", Source: "AUR", Version: "12.0.0-1"}}, packages)
+	suite.Contains(out, "\"Name\": \"yay\"")
+
+	code, out, _ = query("search", "pikaur")
+	suite.Equal(QueryNotFound, code)
+	suite.Equal("[]\n", out)
+
+	// qualifiers don't affect the ranking
+	ps.conf.SearchMode = "Fuzzy"
+	code, out, _ = query("search", "yay aur:")
+	suite.Equal(QueryFound, code)
+	packages = []Package{}
+	suite.Nil(json.Unmarshal([]byte(out), &packages))
+	names := []string{}
+	for _, p := range packages {
+		names = append(names, p.Name)
+	}
+	suite.Equal([]string{"yay", "ayay", "yay-bin"}, names)
+	ps.conf.SearchMode = "StartsWith"
+
+	// info
+	code, out, errOut = query("info", "paru", "pikaur")
+	suite.Equal(QueryFound, code)
+	suite.Empty(errOut)
+	infos := []InfoRecord{}
+	suite.Nil(json.Unmarshal([]byte(out), &infos))
+	suite.Len(infos, 1)
+	suite.Equal("paru", infos[0].Name)
+	suite.Equal("AUR", infos[0].Source)
+	suite.Equal([]DependencySatisfier{{DepName: "git", DepType: "dep"}}, infos[0].DepsAndSatisfiers)
+
+	code, out, _ = query("info", "pikaur")
+	suite.Equal(QueryNotFound, code)
+	suite.Equal("[]\n", out)
+
+	// invalid commands
+	for _, c := range [][]string{{"search"}, {"search", "a", "b"}, {"info"}, {"nonsense"}} {
+		code, out, errOut = query(c[0], c[1:]...)
+		suite.Equal(QueryError, code, c)
+		suite.Empty(out)
+		suite.NotEmpty(errOut)
+	}
+
+	// a failing source does not hide the results of the others
+	ps.sources = append(ps.sources, &testSource{name: "broken", err: errors.New("source broken")})
+	code, out, errOut = query("search", "paru")
+	suite.Equal(QueryFound, code)
+	suite.Contains(out, "paru")
+	suite.Equal("source broken\n", errOut)
+
+	// but we can't tell if nothing was found
+	code, out, errOut = query("search", "pikaur")
+	suite.Equal(QueryError, code)
+	suite.Equal("[]\n", out)
+	suite.Equal("source broken\n", errOut)
+
+	// the same goes for package information
+	ps.sources = []PackageSource{
+		&testSource{name: "repo", infos: []InfoRecord{{Name: "bar", Source: "repo"}}},
+		&testSource{name: "broken", err: errors.New("source broken")},
+	}
+	code, out, errOut = query("info", "bar", "foo")
+	suite.Equal(QueryFound, code)
+	infos = []InfoRecord{}
+	suite.Nil(json.Unmarshal([]byte(out), &infos))
+	suite.Len(infos, 1)
+	suite.Equal("bar", infos[0].Name)
+	suite.Equal("source broken\n", errOut)
+
+	code, out, errOut = query("info", "foo")
+	suite.Equal(QueryError, code)
+	suite.Equal("[]\n", out)
+	suite.Equal("source broken\n", errOut)
+}
+
+func (suite *pacseekTestSuite) TestCachedPackageInfo() {
+	h, err := initPacmanDbs("/var/lib/pacman", "/etc/pacman.conf", []string{})
+	suite.NotNil(h, err)
+	suite.Nil(err, err)
+	defer h.Release()
+
+	ps := UI{
+		conf:        config.Defaults(),
+		alpmHandle:  h,
+		locker:      &sync.RWMutex{},
+		cacheInfo:   cache.New(time.Minute, time.Minute),
+		cacheSearch: cache.New(time.Minute, time.Minute),
+		sources: []PackageSource{
+			&testSource{name: "one", infos: []InfoRecord{{Name: "pacman", Source: "one", Depends: []string{"glibc"}}}},
+		},
+	}
+	ps.cacheSearchAndPackageInfo(context.Background(), []Package{{Name: "pacman", Source: "one"}}, "pacman")
+
+	// cached records come with the state of the installed package
+	cached, found := ps.cacheInfo.Get("pacman-one")
+	suite.True(found)
+	info := cached.(InfoRecord)
+	suite.NotEmpty(info.LocalVersion)
+	suite.NotEmpty(info.InstallReason)
+	suite.NotZero(info.InstallDate)
+	suite.NotZero(info.LocalBuildDate)
+	suite.True(info.DepsAndSatisfiers[0].Installed)
+}
+
+func (suite *pacseekTestSuite) TestJobScheduler() {
+	s := newJobScheduler()
+	cancelled := make(chan bool)
+	finished := make(chan bool)
+
+	s.run(jobSearch, func(ctx context.Context) {
+		<-ctx.Done()
+		cancelled <- true
+	})
+	// a job of a different kind must not cancel the search
+	s.run(jobPkgbuild, func(ctx context.Context) {
+		finished <- ctx.Err() == nil
+	})
+	suite.True(<-finished, "pkgbuild job cancelled")
+
+	// a new search supersedes the running one
+	s.run(jobSearch, func(ctx context.Context) {
+		finished <- ctx.Err() == nil
+	})
+	suite.True(<-cancelled, "previous search not cancelled")
+	suite.True(<-finished, "new search cancelled")
+}
+
+func (suite *pacseekTestSuite) TestDiskCache() {
+	suite.T().Setenv("XDG_CACHE_HOME", suite.T().TempDir())
+
+	now := time.Now()
+	items := map[string]cache.Item{
+		"yay-AUR":    {Object: InfoRecord{Name: "yay", Source: "AUR"}, Expiration: now.Add(time.Minute).UnixNano()},
+		"yay":        {Object: []Package{{Name: "yay", Source: "AUR"}}, Expiration: now.Add(time.Hour).UnixNano()},
+		"expired":    {Object: "PKGBUILD", Expiration: now.Add(-time.Minute).UnixNano()},
+		"#upgrades#": {Object: []InfoRecord{}, Expiration: now.Add(time.Minute).UnixNano()},
+	}
+	suite.Nil(saveDiskCache(diskCacheInfo, items))
+
+	loaded, err := loadDiskCache(diskCacheInfo, 10*time.Minute)
+	suite.Nil(err, err)
+	suite.Len(loaded, 2)
+	suite.Equal(InfoRecord{Name: "yay", Source: "AUR"}, loaded["yay-AUR"].Object)
+	suite.Equal([]Package{{Name: "yay", Source: "AUR"}}, loaded["yay"].Object)
+	// expiry is capped to the configured cache expiry
+	suite.LessOrEqual(loaded["yay"].Expiration, now.Add(11*time.Minute).UnixNano())
+
+	// outdated format version
+	file, _ := diskCachePath(diskCacheInfo)
+	f, _ := os.Create(file)
+	gob.NewEncoder(f).Encode(diskCacheFile{Version: diskCacheVersion - 1, Items: items})
+	f.Close()
+	loaded, err = loadDiskCache(diskCacheInfo, 10*time.Minute)
+	suite.Nil(err, err)
+	suite.Len(loaded, 0)
+
+	suite.Nil(wipeDiskCache())
+	suite.NoFileExists(file)
+}
+
+func (suite *pacseekTestSuite) TestComposeCommand() {
+	yay := InfoRecord{Name: "yay", Source: "AUR", PackageBase: "yay", OptDepends: []string{"sudo"}}
+	paru := InfoRecord{Name: "paru-bin", Source: "AUR", PackageBase: "paru-bin"}
+	glibc := InfoRecord{Name: "glibc", Source: "core", PackageBase: "glibc"}
+
+	// single package
+	suite.Equal("yay -S yay", composeCommand("yay -S", yay))
+	suite.Equal("yay -S yay --needed", composeCommand("yay -S {pkg} --needed", yay))
+	suite.Equal("pacman -S core/glibc", composeCommand("pacman -S {repo}/{pkg}", glibc))
+	suite.Equal("install https://aur.archlinux.org/yay.git yay", composeCommand("install {giturl} {pkg}", yay))
+	suite.Equal("yay -S --asdeps sudo yay", composeCommand("yay -S --asdeps {optdepends}", yay))
+
+	// multiple packages
+	suite.Equal("yay -S yay glibc", composeCommand("yay -S", yay, glibc))
+	suite.Equal("yay -S yay glibc --needed", composeCommand("yay -S {pkgs} --needed", yay, glibc))
+	suite.Equal("yay -S yay glibc", composeCommand("yay -S {pkg}", yay, glibc))
+	suite.Equal("install yay yay && install paru-bin paru-bin", composeCommand("install {pkgbase}", yay, paru))
+}
+
+func (suite *pacseekTestSuite) TestAurDump() {
+	file := path.Join(suite.T().TempDir(), "packages-meta-ext-v1.json.gz")
+	f, _ := os.Create(file)
+	gz := gzip.NewWriter(f)
+	json.NewEncoder(gz).Encode([]InfoRecord{
+		{Name: "yay-bin", Description: "Yet another yogurt", Version: "12.0.0-1"},
+		{Name: "yay", Description: "Yet another yogurt", Version: "12.0.0-1"},
+		{Name: "paru", Description: "Feature packed AUR helper", Version: "2.0.0-1"},
+	})
+	gz.Close()
+	f.Close()
+
+	dump := newAurDump("file://"+file, time.Hour)
+	suite.Nil(dump.load())
+
+	// search
+	search := func(term, mode, by string, max int) []Package {
+		packages, err := dump.search(term, mode, by, max)
+		suite.Nil(err, err)
+		return packages
+	}
+	suite.Equal([]Package{{Name: "yay", Source: "AUR", Version: "12.0.0-1"}, {Name: "yay-bin", Source: "AUR", Version: "12.0.0-1"}}, search("yay", "StartsWith", "Name", 100))
+	suite.Len(search("ay", "StartsWith", "Name", 100), 0)
+	suite.Len(search("ay", "Contains", "Name", 100), 2)
+	suite.Len(search("helper", "Contains", "Name & Description", 100), 1)
+	suite.Len(search("yay", "StartsWith", "Name", 1), 1)
+	suite.Equal([]Package{{Name: "yay", Source: "AUR", Version: "12.0.0-1"}}, search("yay -bin", "StartsWith", "Name", 100))
+	suite.Len(search("yay repo:extra", "StartsWith", "Name", 100), 0)
+	suite.Len(search("aur:", "StartsWith", "Name", 100), 3)
+
+	// info
+	sr, notFound := dump.info("paru", "pikaur")
+	suite.Equal(1, sr.Resultcount)
+	suite.Equal("AUR", sr.Results[0].Source)
+	suite.Equal("2.0.0-1", sr.Results[0].Version)
+	suite.Equal([]string{"pikaur"}, notFound)
+
+	// suggest
+	suite.Equal([]string{"yay", "yay-bin"}, dump.suggest("ya"))
+	suite.Equal([]string{}, dump.suggest("pi"))
+
+	// missing file
+	suite.NotNil(newAurDump(file+".missing", time.Hour).load())
+
+	// updates run in the background, failures are not retried right away
+	dump = newAurDump("file://"+file, time.Hour)
+	suite.Error(dump.ready())
+	<-dump.update()
+	suite.Nil(dump.ready())
+	suite.Nil(dump.update())
+
+	missing := newAurDump(file+".missing", time.Hour)
+	<-missing.update()
+	suite.Error(missing.ready())
+	suite.Nil(missing.update())
+
+	// an outdated index is used until the update is finished
+	dump.refresh = 0
+	done := dump.update()
+	suite.NotNil(done)
+	suite.Nil(dump.ready())
+	<-done
+}
+
+func (suite *pacseekTestSuite) TestSearchModes() {
+	matches := func(term, mode, by, name, desc string) bool {
+		m, err := newPackageMatcher(term, mode, by)
+		suite.Nil(err, err)
+		return m.matches(name, desc)
+	}
+	suite.True(matches("yay", "StartsWith", "Name", "yay-bin", ""))
+	suite.False(matches("bin", "StartsWith", "Name", "yay-bin", ""))
+	suite.True(matches("bin", "Contains", "Name", "yay-bin", ""))
+	suite.True(matches("^ya.*bin$", "Regex", "Name", "yay-bin", ""))
+	suite.True(matches("YAY", "Regex", "Name", "yay-bin", ""))
+	suite.False(matches("^bin", "Regex", "Name", "yay-bin", ""))
+	suite.True(matches("yybn", "Fuzzy", "Name", "yay-bin", ""))
+	suite.False(matches("byy", "Fuzzy", "Name", "yay-bin", ""))
+	suite.True(matches("yogurt", "Contains", "Name & Description", "yay", "Yet another Yogurt"))
+	suite.False(matches("yogurt", "Contains", "Name", "yay", "Yet another Yogurt"))
+	suite.False(matches("yat", "Fuzzy", "Name & Description", "paru", "Yet another yogurt"))
+
+	_, err := newPackageMatcher("yay(", "Regex", "Name")
+	suite.NotNil(err)
+
+	// AUR search-terms
+	for term, expected := range map[string]string{
+		"^python-.*req":   "python-",
+		"(yay|paru)-bin":  "-bin",
+		"linux[0-9]+-lts": "linux",
+		"ab*c":            "",
+	} {
+		aurTerm, err := aurSearchTerm(term, "Regex")
+		if expected == "" {
+			suite.NotNil(err, term)
+			continue
+		}
+		suite.Nil(err, err)
+		suite.Equal(expected, aurTerm, term)
+	}
+	aurTerm, err := aurSearchTerm("py-requests", "Fuzzy")
+	suite.Nil(err, err)
+	suite.Equal("requests", aurTerm)
+	aurTerm, err = aurSearchTerm("yay", "Contains")
+	suite.Nil(err, err)
+	suite.Equal("yay", aurTerm)
+
+	// fuzzy ranking
+	packages := []Package{{Name: "yay-git"}, {Name: "yaourt"}, {Name: "yay"}, {Name: "paru"}}
+	sortPackages(packages, "yay", "Fuzzy")
+	suite.Equal("yay", packages[0].Name)
+	suite.Equal("yay-git", packages[1].Name)
+	suite.Equal([]Package{{Name: "paru"}, {Name: "yaourt"}}, packages[2:])
+}
+
+func (suite *pacseekTestSuite) TestSearchQuery() {
+	q, err := parseSearchQuery("qt -git repo:extra votes:>10 installed:no")
+	suite.Nil(err, err)
+	suite.Equal("qt", q.Term)
+	suite.Equal([]queryFilter{
+		{Field: "name", Value: "git", Negate: true},
+		{Field: "repo", Value: "extra"},
+		{Field: "votes", Value: ">10"},
+		{Field: "installed", Value: "no"},
+	}, q.Filters)
+	suite.False(q.allowsSource(true))
+	suite.True(q.allowsSource(false))
+
+	// regular expressions are not mistaken for qualifiers
+	q, err = parseSearchQuery("(?:qt)6")
+	suite.Nil(err, err)
+	suite.Equal("(?:qt)6", q.Term)
+
+	for _, invalid := range []string{"foo:bar", "installed:maybe", "votes:>many", "maintainer:", " "} {
+		_, err = parseSearchQuery(invalid)
+		suite.NotNil(err, invalid)
+	}
+
+	// source filters
+	q, _ = parseSearchQuery("aur:")
+	suite.True(q.allowsSource(true))
+	suite.False(q.allowsSource(false))
+	q, _ = parseSearchQuery("yay -aur:")
+	suite.False(q.allowsSource(true))
+	suite.True(q.allowsSource(false))
+
+	// lookups
+	q, _ = parseSearchQuery("provides:java-runtime")
+	f, ok := q.lookupFilter()
+	suite.True(ok)
+	suite.Equal("provides", f.Field)
+	suite.True(q.needsDetails())
+
+	jre := InfoRecord{
+		Name:       "jre17-openjdk",
+		Source:     "extra",
+		Provides:   []string{"java-runtime=17", "jre17-openjdk-headless"},
+		Depends:    []string{"jre17-openjdk-headless=17.0.8", "giflib"},
+		License:    []string{"custom", "GPL2"},
+		Maintainer: "Arch Packager <arch@example.org>",
+	}
+	aur := InfoRecord{Name: "yay-git", Source: "AUR", NumVotes: 50, OutOfDate: 1690000000, Maintainer: "jguer"}
+	for query, expected := range map[string][2]bool{
+		"provides:java-runtime":      {true, false},
+		"provides:jre17-openjdk":     {true, false},
+		"depends:giflib":             {true, false},
+		"depends:gif":                {false, false},
+		"license:gpl":                {true, false},
+		"maintainer:arch":            {true, false},
+		"maintainer:JGUER":           {false, true},
+		"repo:extra":                 {true, false},
+		"-repo:extra":                {false, true},
+		"aur:no":                     {true, false},
+		"outofdate:yes":              {false, true},
+		"votes:>=50":                 {false, true},
+		"votes:<50":                  {true, false},
+		"votes:50":                   {false, true},
+		"-git":                       {true, false},
+		"-openjdk maintainer:jguer":  {false, true},
+		"aur: outofdate:no votes:>1": {false, false},
+	} {
+		q, err := parseSearchQuery(query)
+		suite.Nil(err, err)
+		suite.Equal(expected[0], q.matches(jre), query)
+		suite.Equal(expected[1], q.matches(aur), query)
+	}
+
+	q, _ = parseSearchQuery("yay installed:yes")
+	suite.True(q.matchesPackage(Package{Name: "yay", IsInstalled: true}))
+	suite.False(q.matchesPackage(Package{Name: "yay"}))
+}
+
+func (suite *pacseekTestSuite) TestSearchBy() {
+	q, _ := parseSearchQuery("java-runtime -git")
+	q.applySearchBy("Provides")
+	suite.Equal("", q.Term)
+	f, ok := q.lookupFilter()
+	suite.True(ok)
+	suite.Equal(queryFilter{Field: "provides", Value: "java-runtime"}, f)
+	suite.Equal([]queryFilter{{Field: "name", Value: "git", Negate: true}}, removeFilter(q.Filters, f))
+
+	q, _ = parseSearchQuery("yay")
+	q.applySearchBy("Name & Description")
+	suite.Equal("yay", q.Term)
+	suite.Len(q.Filters, 0)
+
+	aur := InfoRecord{Name: "yay", Source: "AUR", Keywords: []string{"AUR", "helper"}, CoMaintainers: []string{"Jguer"}, Submitter: "jguer", MakeDepends: []string{"go>=1.21"}}
+	for by, term := range map[string]string{"Keywords": "helper", "Co-Maintainers": "jguer", "Submitter": "JGUER", "MakeDepends": "go"} {
+		q, _ := parseSearchQuery(term)
+		q.applySearchBy(by)
+		suite.True(q.matches(aur), by)
+		suite.True(q.needsDetails(), by)
+		suite.False(q.matches(InfoRecord{Name: "pacman", Source: "core"}), by)
+	}
+	for _, by := range searchByOptions()[2 : len(searchByOptions())-1] {
+		_, ok := searchByFields[by]
+		suite.True(ok, by)
+	}
+}
+
+func (suite *pacseekTestSuite) TestMatchingFiles() {
+	files := []alpm.File{{Name: "usr/"}, {Name: "usr/bin/"}, {Name: "usr/bin/yay"}, {Name: "usr/share/man/man8/yay.8.gz"}, {Name: "usr/share/yay/yay.conf"}}
+
+	match, _ := newPackageMatcher("yay", "StartsWith", "Name")
+	suite.Equal([]string{"/usr/bin/yay", "/usr/share/man/man8/yay.8.gz", "/usr/share/yay/yay.conf"}, matchingFiles(files, match.name, false))
+
+	match, _ = newPackageMatcher(`yay$`, "Regex", "Name")
+	suite.Equal([]string{"/usr/bin/yay"}, matchingFiles(files, match.name, false))
+
+	// paths are matched as a whole
+	match, _ = newPackageMatcher("usr/bin/", "StartsWith", "Name")
+	suite.Equal([]string{"/usr/bin/yay"}, matchingFiles(files, match.name, true))
+
+	match, _ = newPackageMatcher("bin", "StartsWith", "Name")
+	suite.Len(matchingFiles(files, match.name, false), 0)
+}
+
+func (suite *pacseekTestSuite) TestCheckPackageFiles() {
+	root := suite.T().TempDir()
+	os.MkdirAll(path.Join(root, "etc/foo"), 0755)
+	os.WriteFile(path.Join(root, "etc/foo/foo.conf"), []byte("original\n"), 0644)
+	os.WriteFile(path.Join(root, "etc/foo/bar.conf"), []byte("changed\n"), 0644)
+
+	files := []alpm.File{{Name: "etc/foo/"}, {Name: "etc/foo/foo.conf"}, {Name: "etc/foo/bar.conf"}, {Name: "etc/foo/baz.conf"}, {Name: "usr/bin/foo"}}
+	sum, err := md5File(path.Join(root, "etc/foo/foo.conf"))
+	suite.Nil(err, err)
+	suite.Equal("88fa9f694690e11239096536ccf2702b", sum)
+	backups := []alpm.BackupFile{
+		{Name: "etc/foo/foo.conf", Hash: sum},
+		{Name: "etc/foo/bar.conf", Hash: sum},
+		{Name: "etc/foo/baz.conf", Hash: sum},
+	}
+
+	suite.Equal([]packageFile{
+		{Path: "/etc/foo/"},
+		{Path: "/etc/foo/foo.conf", Backup: true},
+		{Path: "/etc/foo/bar.conf", Backup: true, Modified: true},
+		{Path: "/etc/foo/baz.conf", Backup: true, Missing: true},
+		{Path: "/usr/bin/foo", Missing: true},
+	}, checkPackageFiles(context.Background(), root, files, backups))
+}
+
+func (suite *pacseekTestSuite) TestFindOrphans() {
+	orphans, size := findOrphans(map[string]orphanCandidate{
+		"app":     {AsDependency: false, Size: 1},
+		"libfoo":  {AsDependency: true, RequiredBy: []string{"app"}, Size: 2},
+		"old":     {AsDependency: true, Size: 4},
+		"libold":  {AsDependency: true, RequiredBy: []string{"old"}, Size: 8},
+		"libbase": {AsDependency: true, RequiredBy: []string{"libold", "libfoo"}, Size: 16},
+		"libdeep": {AsDependency: true, RequiredBy: []string{"libold"}, Size: 32},
+		"cycle-a": {AsDependency: true, RequiredBy: []string{"cycle-b"}, Size: 64},
+		"cycle-b": {AsDependency: true, RequiredBy: []string{"cycle-a"}, Size: 128},
+	})
+	// packages only required by each other are not detected (same as pacman)
+	suite.Equal([]string{"libdeep", "libold", "old"}, orphans)
+	suite.Equal(int64(44), size)
+
+	orphans, size = findOrphans(map[string]orphanCandidate{"app": {}})
+	suite.Len(orphans, 0)
+	suite.Zero(size)
+
+	suite.Equal("512 B", formatSize(512))
+	suite.Equal("1.5 KiB", formatSize(1536))
+	suite.Equal("2.0 GiB", formatSize(2*1024*1024*1024))
+}
+
+func (suite *pacseekTestSuite) TestInstallReasonCommand() {
+	suite.Equal("sudo pacman -D --asdeps yay", installReasonCommand("sudo pacman -D {reason} {pkg}", InfoRecord{Name: "yay", InstallReason: "Explicit"}))
+	suite.Equal("sudo pacman -D --asexplicit yay", installReasonCommand("sudo pacman -D {reason}", InfoRecord{Name: "yay", InstallReason: "Dependency"}))
+}
+
+func (suite *pacseekTestSuite) TestUpgradeSizes() {
+	up := []InfoRecord{
+		{Name: "linux", Source: "core", DownloadSize: 100, InstalledSize: 1000, LocalSize: 900},
+		{Name: "firefox", Source: "extra", DownloadSize: 50, InstalledSize: 500, LocalSize: 700},
+		{Name: "ignored", Source: "extra", DownloadSize: 10, InstalledSize: 10, IsIgnored: true},
+	}
+	download, delta, aur := upgradeSizes(up)
+	suite.Equal(int64(150), download)
+	suite.Equal(int64(-100), delta)
+	suite.False(aur)
+
+	_, _, aur = upgradeSizes(append(up, InfoRecord{Name: "yay", Source: "AUR"}))
+	suite.True(aur)
+
+	suite.Equal("-100 B", formatSizeDelta(-100))
+	suite.Equal("+1.0 KiB", formatSizeDelta(1024))
+}
+
+func (suite *pacseekTestSuite) TestPackageListColumns() {
+	columns, err := packageListColumns([]string{"source", " Size", "Installed", "Size"})
+	suite.Nil(err)
+	names := []string{}
+	for _, c := range columns {
+		names = append(names, c.Name)
+	}
+	// the name column is always shown, duplicates are ignored
+	suite.Equal([]string{"Name", "Source", "Size", "Installed"}, names)
+
+	columns, err = packageListColumns([]string{"Name", "Nope"})
+	suite.NotNil(err)
+	suite.Len(columns, 1)
+
+	col, ok := packageColumnByKey('Z')
+	suite.True(ok)
+	suite.Equal("Size", col.Name)
+	_, ok = packageColumnByKey('X')
+	suite.False(ok)
+
+	packages := []Package{
+		{Name: "b", Size: 10, IsInstalled: true},
+		{Name: "a", Size: 10},
+		{Name: "c", Size: 5},
+	}
+	sortPackagesBy(packages, col, false)
+	suite.Equal([]string{"c", "a", "b"}, []string{packages[0].Name, packages[1].Name, packages[2].Name})
+	sortPackagesBy(packages, col, true)
+	suite.Equal([]string{"a", "b", "c"}, []string{packages[0].Name, packages[1].Name, packages[2].Name})
+
+	col, _ = packageColumnByKey('I')
+	sortPackagesBy(packages, col, true)
+	suite.Equal("b", packages[0].Name)
+}
+
+func (suite *pacseekTestSuite) TestDepTree() {
+	repo := map[string]depTarget{
+		"glibc":       {Record: InfoRecord{Name: "glibc", Source: "core"}, Installed: true},
+		"go":          {Record: InfoRecord{Name: "go", Source: "extra", DownloadSize: 100}},
+		"libfoo>=2":   {Record: InfoRecord{Name: "libfoo", Source: "extra", Depends: []string{"libbar"}, DownloadSize: 10}},
+		"libbar":      {Record: InfoRecord{Name: "libbar", Source: "AUR", Depends: []string{"libfoo>=2", "glibc"}, MakeDepends: []string{"go"}}},
+		"fancy: docs": {Record: InfoRecord{Name: "fancy", Source: "extra", Depends: []string{"libfoo>=2"}}, Conflicts: []string{"plain"}},
+	}
+	calls := 0
+	resolve := func(ctx context.Context, deps []string) map[string]depTarget {
+		calls++
+		targets := map[string]depTarget{}
+		for _, dep := range deps {
+			if t, ok := repo[dep]; ok {
+				targets[dep] = t
+			}
+		}
+		return targets
+	}
+
+	app := InfoRecord{
+		Name:        "app",
+		Source:      "AUR",
+		Depends:     []string{"libfoo>=2", "glibc", "ghost"},
+		MakeDepends: []string{"go"},
+		OptDepends:  []string{"fancy: docs", "phantom: extras"},
+	}
+	root := buildDepTree(context.Background(), app, resolve, true)
+
+	names := []string{}
+	for _, c := range root.Children {
+		names = append(names, c.Name)
+	}
+	suite.Equal([]string{"libfoo", "glibc", "ghost", "go", "fancy", "phantom"}, names)
+	suite.True(root.Children[2].Missing)
+	suite.Equal("make", root.Children[3].Type)
+	suite.Equal("opt", root.Children[4].Type)
+
+	// libfoo -> libbar -> libfoo is a cycle
+	libbar := root.Children[0].Children[0]
+	suite.Equal("libbar", libbar.Name)
+	suite.True(libbar.Children[0].Cycle)
+	// go was expanded as a dependency of app already
+	suite.True(libbar.Children[2].Repeated)
+	// libfoo below the optional dependency is not expanded again
+	suite.True(root.Children[4].Children[0].Repeated)
+	// resolved level by level: 3 levels of required deps, optional deps and their deps
+	suite.Equal(5, calls)
+
+	s := root.summary()
+	newNames := []string{}
+	for _, r := range s.New {
+		newNames = append(newNames, r.Name)
+	}
+	suite.Equal([]string{"libfoo", "libbar", "go"}, newNames)
+	suite.Equal(1, s.Aur)
+	suite.Equal(1, s.Optional)
+	// missing and conflicting optional dependencies are not a problem for the installation
+	suite.Equal([]string{"ghost"}, s.Missing)
+	suite.Len(s.Conflicts, 0)
+	suite.Equal(1, s.Cycles)
+	suite.Equal(int64(110), s.DownloadSize)
+
+	// optional dependencies are not resolved for transactions
+	calls = 0
+	root = buildDepTree(context.Background(), app, resolve, false)
+	suite.Len(root.Children, 4)
+	suite.Equal(3, calls)
+	t := transaction{}
+	t.addInstalls([]*depNode{root})
+	suite.Equal([]string{"ghost"}, t.Missing)
+	suite.Len(t.Conflicts, 0)
+
+	suite.Equal("python", depName("python: for scripts"))
+	suite.Equal("java-runtime", depName("java-runtime>=17"))
+}
+
+func (suite *pacseekTestSuite) TestRevDepTree() {
+	installed := map[string]revDepInfo{
+		"zlib":    {RequiredBy: []string{"libpng", "curl"}, OptionalFor: []string{"viewer"}},
+		"libpng":  {RequiredBy: []string{"gimp", "libgfx"}},
+		"libgfx":  {RequiredBy: []string{"libpng"}},
+		"curl":    {Explicit: true, RequiredBy: []string{"pacman"}},
+		"gimp":    {Explicit: true},
+		"viewer":  {Explicit: true},
+		"pacman":  {Explicit: true},
+		"lonely":  {},
+		"missing": {RequiredBy: []string{"gone"}},
+	}
+	lookup := func(name string) (revDepInfo, bool) {
+		info, ok := installed[name]
+		return info, ok
+	}
+
+	root := buildRevDepTree("zlib", lookup)
+	suite.False(root.Explicit)
+	suite.Len(root.Children, 3)
+	libpng, curl, viewer := root.Children[0], root.Children[1], root.Children[2]
+	suite.Equal([]string{"gimp", "libgfx"}, []string{libpng.Children[0].Name, libpng.Children[1].Name})
+	suite.True(libpng.Children[1].Children[0].Cycle)
+	// explicit packages are not expanded any further
+	suite.True(curl.Explicit)
+	suite.Len(curl.Children, 0)
+	suite.True(viewer.Optional)
+
+	// optional dependencies don't need to be removed
+	suite.Equal([]string{"curl", "gimp", "pacman"}, revDepExplicit("zlib", lookup))
+	suite.Len(revDepExplicit("lonely", lookup), 0)
+	suite.Len(buildRevDepTree("missing", lookup).Children, 0)
+}
+
+func (suite *pacseekTestSuite) TestTransaction() {
+	// install
+	tree := func(r InfoRecord, installed bool, deps ...InfoRecord) *depNode {
+		root := &depNode{Name: r.Name, Record: r, Installed: installed}
+		for _, d := range deps {
+			root.Children = append(root.Children, &depNode{Dep: d.Name, Type: "dep", Name: d.Name, Record: d, parent: root})
+		}
+		return root
+	}
+	foo := InfoRecord{Name: "foo", Source: "extra", DownloadSize: 100, InstalledSize: 1000}
+	bar := InfoRecord{Name: "bar", Source: "extra", DownloadSize: 50, InstalledSize: 500, LocalSize: 400}
+	lib := InfoRecord{Name: "lib", Source: "core", DownloadSize: 10, InstalledSize: 100}
+	aur := InfoRecord{Name: "aurpkg", Source: "AUR"}
+
+	t := transaction{}
+	t.addInstalls([]*depNode{tree(foo, false, lib, bar), tree(bar, true, lib), tree(aur, false, lib)})
+	suite.Len(t.Install, 3)
+	suite.Equal([]InfoRecord{lib}, t.Deps)
+	suite.Equal(int64(160), t.DownloadSize)
+	suite.Equal(int64(1200), t.InstallSize)
+	suite.True(t.Aur)
+
+	// removal with and without dependencies
+	installed := map[string]orphanCandidate{
+		"app":    {Size: 1000},
+		"libapp": {AsDependency: true, RequiredBy: []string{"app"}, Size: 100},
+		"libcom": {AsDependency: true, RequiredBy: []string{"libapp", "other"}, Size: 10},
+		"libsub": {AsDependency: true, RequiredBy: []string{"libapp"}, Size: 1},
+		"other":  {Size: 5000},
+		"tool":   {RequiredBy: []string{"app"}, Size: 20},
+	}
+	t = transaction{}
+	t.addRemovals(installed, []string{"app"}, true)
+	suite.Equal([]string{"app"}, t.Remove)
+	suite.Equal([]string{"libapp", "libsub"}, t.Cascade)
+	suite.Len(t.Breaks, 0)
+	suite.Equal(int64(1101), t.FreedSize)
+
+	t = transaction{}
+	t.addRemovals(installed, []string{"libcom"}, false)
+	suite.Len(t.Cascade, 0)
+	suite.Equal([]string{"libapp requires libcom", "other requires libcom"}, t.Breaks)
+	suite.Contains(t.describe(), "net installed size: -10 B")
+	suite.Contains(t.describe(), "[red::b]Breaks dependencies:[-::-] libapp requires libcom, other requires libcom\n")
+
+	t = transaction{Install: []InfoRecord{{Name: "foo", Source: "extra"}}, Remove: []string{"x[y]"}}
+	suite.Contains(t.describe(), "[::b]Install (1):[::-] foo (extra)\n[::b]Remove (1):[::-] x[y[]\n")
+
+	// commands removing dependencies
+	for command, expected := range map[string]bool{
+		"yay -Rs":                          true,
+		"sudo pacman -Rns":                 true,
+		"paru -R":                          false,
+		"sudo pacman --remove --recursive": true,
+		"yay -S":                           false,
+	} {
+		suite.Equal(expected, removesDependencies(command), command)
+	}
+}
+
+func (suite *pacseekTestSuite) TestAurBuildOrder() {
+	node := func(name, base, source string, children ...*depNode) *depNode {
+		n := &depNode{Dep: name, Type: "dep", Name: name, Record: InfoRecord{Name: name, PackageBase: base, Source: source}}
+		for _, c := range children {
+			c.parent = n
+			n.Children = append(n.Children, c)
+		}
+		return n
+	}
+	installed := node("installed-aur", "installed-aur", "AUR")
+	installed.Installed = true
+	optional := node("opt-aur", "opt-aur", "AUR")
+	optional.Type = "opt"
+
+	// app -> lib-split (base "libs") -> core-aur; tool -> lib-split, app
+	app := node("app", "app", "AUR",
+		node("lib-split", "libs", "AUR", node("core-aur", "core-aur", "AUR"), node("zlib", "zlib", "core")),
+		node("lib-other", "libs", "AUR"),
+		installed, optional)
+	tool := node("tool", "tool", "AUR", node("lib-split", "libs", "AUR"), node("app", "app", "AUR"))
+
+	order, err := aurBuildOrder([]*depNode{tool, app})
+	suite.Nil(err)
+	suite.Equal("core-aur, libs, app, tool", buildBases(order))
+	suite.Equal([]string{"lib-split", "lib-other"}, order[1].Deps)
+	// app is installed explicitly since it was requested
+	suite.Equal([]string{"app"}, order[2].Targets)
+	suite.Len(order[2].Deps, 0)
+
+	missing := node("broken", "broken", "AUR", &depNode{Dep: "gone>=1", Missing: true})
+	_, err = aurBuildOrder([]*depNode{missing})
+	suite.EqualError(err, "missing dependencies: gone>=1")
+
+	a := node("a", "cyc", "AUR", node("b", "b", "AUR", node("c", "cyc", "AUR")))
+	_, err = aurBuildOrder([]*depNode{a})
+	suite.Error(err)
+
+	// package files
+	files := []string{
+		"/build/libs/lib-split-1.0-1-x86_64.pkg.tar.zst",
+		"/build/libs/lib-other-2:1.0.r3-2-any.pkg.tar.xz",
+		"/build/libs/lib-split-debug-1.0-1-x86_64.pkg.tar.zst",
+	}
+	suite.Equal("lib-split-debug", builtPackageName(files[2]))
+	matching, err := packageFilesFor(files, []string{"lib-split", "lib-other"})
+	suite.Nil(err)
+	suite.Equal([]string{files[0], files[1]}, matching)
+	_, err = packageFilesFor(files, []string{"lib-split", "lib-git"})
+	suite.Error(err)
+
+	// output is escaped line by line
+	buf := &bytes.Buffer{}
+	w := &escapeWriter{w: buf}
+	fmt.Fprint(w, "[red")
+	suite.Equal("", buf.String())
+	fmt.Fprint(w, "] text\nmore")
+	suite.Equal(tview.Escape("[red] text\n"), buf.String())
+	suite.Nil(w.Flush())
+	suite.Equal(tview.Escape("[red] text\nmore"), buf.String())
+	suite.Nil(w.Flush())
+	suite.Equal(tview.Escape("[red] text\nmore"), buf.String())
+
+	// the rest of the output is written once the command is finished
+	buf.Reset()
+	suite.Nil(runBuildCommand(context.Background(), w, suite.T().TempDir(), "printf", "line\\n[prompt]"))
+	suite.Equal("\n==> printf line\\n[prompt[]\nline\n[prompt[]", buf.String())
+}
+
+func (suite *pacseekTestSuite) TestPkgbuildDiff() {
+	suite.T().Setenv("XDG_CACHE_HOME", suite.T().TempDir())
+
+	srcinfo := "pkgbase = foo\n\tpkgdesc = Foo\n\tpkgver = 1.2\n\tpkgrel = 3\n\tepoch = 1\n\npkgname = foo\n\tpkgver = 9\n"
+	suite.Equal("1:1.2-3", srcinfoVersion(srcinfo))
+	suite.Equal("1.2-3", srcinfoVersion("pkgbase = foo\n\tpkgver = 1.2\n\tpkgrel = 3\n\tepoch = 0\n"))
+	suite.Equal("", srcinfoVersion("pkgbase = foo\n"))
+
+	// no history yet
+	_, ok := pkgbuildBaseline("foo", "1.0-1", "1.1-1")
+	suite.False(ok)
+
+	suite.Nil(savePkgbuildSnapshot("foo", "0.9-1", filePkgbuild, "pkgver=0.9\n"))
+	suite.Nil(savePkgbuildSnapshot("foo", "1.1-1", filePkgbuild, "pkgver=1.1\n"))
+	dir, _ := pkgbuildHistoryDir("foo")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(path.Join(dir, "0.9-1", filePkgbuild), old, old)
+
+	// last viewed version, unless we know the installed one
+	since, ok := pkgbuildBaseline("foo", "1.0-1", "1.2-1")
+	suite.True(ok)
+	suite.Equal("1.1-1", since)
+	suite.Nil(savePkgbuildSnapshot("foo", "1.0-1", filePkgbuild, "pkgver=1.0\nsource=(a)\n"))
+	since, _ = pkgbuildBaseline("foo", "1.0-1", "1.2-1")
+	suite.Equal("1.0-1", since)
+	content, ok := loadPkgbuildSnapshot("foo", "1.0-1", filePkgbuild)
+	suite.True(ok)
+
+	diff, err := unifiedDiff(content, "pkgver=1.2\nsource=(a)\n", filePkgbuild, "1.0-1", "1.2-1")
+	suite.Nil(err)
+	suite.Contains(diff, "--- PKGBUILD 1.0-1\n+++ PKGBUILD 1.2-1\n")
+	suite.Contains(diff, "-pkgver=1.0\n+pkgver=1.2\n source=(a)\n")
+	colored := colorDiff(diff)
+	suite.Contains(colored, "[red]-pkgver=1.0[-:-:-]\n[green]+pkgver=1.2[-:-:-]\n source=(a)\n")
+	suite.Equal("", colorDiff("")[1:])
+
+	empty, _ := unifiedDiff(content, content, filePkgbuild, "1.0-1", "1.0-1")
+	suite.Equal("", empty)
+}
+
+func (suite *pacseekTestSuite) TestPkgbuildLinter() {
+	pkgbuild := `# Maintainer: someone
+pkgname=foo
+pkgver=1.0
+pkgrel=1
+url="http://example.org"
+source=("foo-$pkgver.tar.gz::http://example.org/foo.tar.gz"
+        "git+https://github.com/foo/bar.git"
+        "https://paste.rs/abc")
+sha256sums=('SKIP'
+            'SKIP'
+            '0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef')
+b2sums_x86_64=('SKIP')
+source_x86_64=("local.patch")
+
+build() {
+  cd "${srcdir}/foo-${pkgver}"
+  curl -s https://example.org/install.sh | bash
+  echo "aGVsbG8=" | base64 -d > payload
+  make
+}
+
+package()
+{
+  sudo make install
+  install -Dm644 LICENSE "$pkgdir/usr/share/licenses/$pkgname/LICENSE"
+  install -Dm644 foo.conf /etc/foo.conf
+  wget https://example.org/extra
+  echo done > /dev/null
+}
+`
+	rules, err := compileLintRules(nil)
+	suite.Nil(err)
+	findings := lintPkgbuild(pkgbuild, rules)
+	found := []string{}
+	for _, f := range findings {
+		found = append(found, fmt.Sprintf("%d %s", f.Line, f.Rule.ID))
+	}
+	suite.Equal([]string{
+		"6 http-source",
+		"8 pastebin",
+		"9 skip-checksum",
+		"17 curl-pipe-shell",
+		"18 base64",
+		"24 sudo",
+		"26 write-outside-pkgdir",
+		"27 network-in-package",
+	}, found)
+	suite.Equal("high", lintRisk(findings))
+	suite.Equal("none", lintRisk(nil))
+
+	p := parsePkgbuild(pkgbuild)
+	suite.Equal("build", p.Scopes[16])
+	suite.Equal("package", p.Scopes[23])
+	suite.Equal("source", p.Scopes[6])
+	suite.Len(p.Arrays["source"], 3)
+
+	// user rules replace / disable built-in rules or add new ones
+	rules, err = compileLintRules([]config.PkgbuildRule{
+		{ID: "sudo", Severity: "low", Pattern: `\bsudo\b`},
+		{ID: "pastebin"},
+		{ID: "skip-checksum", Severity: "low"},
+		{ID: "make", Description: "runs make", Severity: "LOW", Pattern: `^\s*make\b`, Scope: `^build$`},
+	})
+	suite.Nil(err)
+	found = []string{}
+	for _, f := range lintPkgbuild(pkgbuild, rules) {
+		if f.Rule.Severity == "low" || f.Rule.Severity == "LOW" {
+			found = append(found, fmt.Sprintf("%d %s", f.Line, f.Rule.ID))
+		}
+	}
+	suite.Equal([]string{"19 make", "24 sudo"}, found)
+
+	_, err = compileLintRules([]config.PkgbuildRule{{ID: "x", Severity: "critical", Pattern: "x"}})
+	suite.Error(err)
+	_, err = compileLintRules([]config.PkgbuildRule{{ID: "x", Severity: "high", Pattern: "("}})
+	suite.Error(err)
+}
+
+func (suite *pacseekTestSuite) TestRepoFiles() {
+	page := `<tr><td class='ls-mode'>-rw-r--r--</td><td><a class='ls-blob' href='/cgit/aur.git/tree/.SRCINFO?h=foo'>.SRCINFO</a></td></tr>
+<tr><td class='ls-mode'>-rw-r--r--</td><td><a class='ls-blob' href='/cgit/aur.git/tree/PKGBUILD?h=foo'>PKGBUILD</a></td></tr>
+<tr><td class='ls-mode'>-rw-r--r--</td><td><a class='ls-blob install' href='/cgit/aur.git/tree/foo.install?h=foo'>foo.install</a></td></tr>
+<tr><td class='ls-mode'>-rw-r--r--</td><td><a class='ls-blob patch' href='/cgit/aur.git/tree/fix%26build.patch?h=foo'>fix&amp;build.patch</a></td></tr>
+<tr><td class='ls-mode'>d---------</td><td><a class='ls-dir' href='/cgit/aur.git/tree/keys?h=foo'>keys</a></td></tr>`
+	files := parseCgitTree(page)
+	suite.Equal([]string{"PKGBUILD", ".SRCINFO", "fix&build.patch", "foo.install"}, files)
+	file, ok := installScript(files)
+	suite.True(ok)
+	suite.Equal("foo.install", file)
+	_, ok = installScript([]string{"PKGBUILD"})
+	suite.False(ok)
+
+	files, err := parseGitlabTree([]byte(`[{"path":"keys/pgp/A.asc","type":"blob"},{"path":"keys","type":"tree"},{"path":"PKGBUILD","type":"blob"},{"path":"foo.service","type":"blob"}]`))
+	suite.Nil(err)
+	suite.Equal([]string{"PKGBUILD", "foo.service", "keys/pgp/A.asc"}, files)
+	_, err = parseGitlabTree([]byte(`{"message":"404 Project Not Found"}`))
+	suite.Error(err)
+
+	suite.Equal("https://aur.archlinux.org/cgit/aur.git/tree/?h=foo", repoFilesUrl("AUR", "foo"))
+	suite.Equal("https://gitlab.archlinux.org/api/v4/projects/archlinux%2Fpackaging%2Fpackages%2Fgtk2plus/repository/tree?recursive=true&per_page=100", repoFilesUrl("extra", "gtk2+"))
+	suite.Equal("https://aur.archlinux.org/cgit/aur.git/plain/foo.install?h=foo", repoFileUrl("AUR", "foo", "foo.install"))
+	suite.Equal("https://gitlab.archlinux.org/archlinux/packaging/packages/foo/-/raw/main/keys/pgp/A.asc", repoFileUrl("core", "foo", "keys/pgp/A.asc"))
+	suite.Equal(getPkgbuildUrl("core", "foo"), repoFileUrl("core", "foo", filePkgbuild))
+	suite.True(hasRepoFiles("AUR"))
+	suite.False(hasRepoFiles("chaotic-aur"))
+
+	suite.Equal("bash", repoFileLexer("PKGBUILD"))
+	suite.Equal("bash", repoFileLexer("foo.install"))
+	suite.Equal("ini", repoFileLexer(".SRCINFO"))
+	suite.Equal("diff", repoFileLexer("fix.patch"))
+	suite.Equal("systemd", repoFileLexer("sub/foo.service"))
+	suite.Equal("plaintext", repoFileLexer("LICENSE"))
+}
+
+func (suite *pacseekTestSuite) TestCommitHistory() {
+	page := `<table class='list nowrap'><tr class='nohover'><th class='left'>Age</th><th class='left'>Commit message</th><th class='left'>Author</th></tr>
+<tr><td><span title='2024-03-12 14:33:28 +0100'>2024-03-12</span></td><td><a href='/cgit/aur.git/commit/?h=foo&amp;id=c3'>Adopt package &amp; update</a><span class='decoration'><a class='deco' href='/cgit/aur.git/commit/?h=foo&amp;id=c3'>HEAD</a></span></td><td>Mallory</td></tr>
+<tr><td><span title='2023-01-02 10:00:00 +0000'>2023-01-02</span></td><td><a href='/cgit/aur.git/commit/?h=foo&amp;id=b2'>Update to 1.1</a></td><td>Alice</td></tr>
+<tr><td><span title='2022-05-01 09:00:00 +0000'>2022-05-01</span></td><td><a href='/cgit/aur.git/commit/?h=foo&amp;id=a1'>Initial import</a></td><td>Alice</td></tr>
+</table>`
+	commits := parseCgitLog(page)
+	suite.Len(commits, 3)
+	suite.Equal("c3", commits[0].ID)
+	suite.Equal("Adopt package & update", commits[0].Message)
+	suite.Equal("Mallory", commits[0].Author)
+	suite.Equal(time.Date(2024, 3, 12, 13, 33, 28, 0, time.UTC), commits[0].Date.UTC())
+	suite.True(commits[0].NewAuthor)
+	suite.True(commits[0].MaintainerChange)
+	suite.False(commits[1].NewAuthor)
+	suite.False(commits[1].MaintainerChange)
+	suite.False(commits[2].NewAuthor)
+
+	commits, err := parseGitlabCommits([]byte(`[{"id":"f00","title":"upgpkg: 1.2-1","author_name":"Bob","authored_date":"2024-03-12T14:33:28.000+01:00"},
+		{"id":"ba5","title":"Orphaned package","author_name":"Alice","authored_date":"2023-01-02T10:00:00.000+00:00"},
+		{"id":"1a1","title":"initial commit","author_name":"Alice","authored_date":"2022-05-01T09:00:00.000+00:00"}]`))
+	suite.Nil(err)
+	suite.Len(commits, 3)
+	suite.True(commits[0].NewAuthor)
+	suite.False(commits[0].MaintainerChange)
+	suite.False(commits[1].NewAuthor)
+	suite.True(commits[1].MaintainerChange)
+	suite.Equal(2024, commits[0].Date.Year())
+	_, err = parseGitlabCommits([]byte(`{"message":"404 Project Not Found"}`))
+	suite.Error(err)
+
+	// with only the latest commits, authors are new compared to the oldest commit we got
+	truncated := flagCommits([]gitCommit{
+		{Author: "Mallory", Message: "Update"},
+		{Author: "Alice", Message: "Update"},
+		{Author: "Alice", Message: "Took over maintenance"},
+	}, true)
+	suite.True(truncated[0].NewAuthor)
+	suite.False(truncated[1].NewAuthor)
+	suite.False(truncated[2].NewAuthor)
+	suite.True(truncated[2].MaintainerChange)
+	suite.False(historyTruncated("AUR", commits))
+	suite.True(historyTruncated("AUR", make([]gitCommit, cgitLogPageSize)))
+	suite.False(historyTruncated("extra", make([]gitCommit, cgitLogPageSize)))
+
+	suite.Equal("https://aur.archlinux.org/cgit/aur.git/log/?h=foo", commitLogUrl("AUR", "foo"))
+	suite.Equal("https://aur.archlinux.org/cgit/aur.git/patch/?h=foo&id=c3", commitPatchUrl("AUR", "foo", "c3"))
+	suite.Equal("https://gitlab.archlinux.org/api/v4/projects/archlinux%2Fpackaging%2Fpackages%2Ffoo/repository/commits?per_page=100", commitLogUrl("extra", "foo"))
+	suite.Equal("https://gitlab.archlinux.org/archlinux/packaging/packages/foo/-/commit/f00.patch", commitPatchUrl("extra", "foo", "f00"))
+}
+
+func (suite *pacseekTestSuite) TestAurComments() {
+	page := `<div class="comments package-comments">
+<div class="comments-header"><h3><span class="text">Pinned Comments</span></h3></div>
+<h4 id="comment-3" class="comment-header">
+    <a href="/account/alice" title="View account information for alice">alice</a> commented on
+    <a href="#comment-3" class="date">2024-01-05 10:11 (UTC)</a>
+</h4>
+<div id="comment-3-content" class="article-content">
+    <div>
+        <p>Please read the <a href="https://wiki">wiki</a> &amp; check <code>foo.install</code></p>
+    </div>
+</div>
+</div>
+<div class="comments package-comments">
+<div class="comments-header"><h3><span class="text">Latest Comments</span></h3></div>
+<h4 id="comment-2" class="comment-header">
+    bob commented on
+    <a href="#comment-2" class="date">2024-03-01 08:00 (UTC)</a>
+</h4>
+<div id="comment-2-content" class="article-content">
+    <div>
+        <p>
+            Build fails with gcc 14:<br />
+            error: foo
+        </p>
+    </div>
+</div>
+<h4 id="comment-1" class="comment-header">
+    carol commented on
+    <a href="#comment-1" class="date">2023-12-24 18:30 (UTC)</a>
+</h4>
+<div id="comment-1-content" class="article-content">
+    <div>
+        <p>Works, thanks!</p>
+        <p>second paragraph</p>
+    </div>
+</div>
+</div>
+<footer>aurweb</footer>`
+	comments := parseAurComments(page)
+	suite.Len(comments, 3)
+	suite.Equal("3", comments[0].ID)
+	suite.True(comments[0].Pinned)
+	suite.Equal("alice", comments[0].Author)
+	suite.Equal("Please read the wiki & check foo.install", comments[0].Text)
+	suite.Equal(time.Date(2024, 1, 5, 10, 11, 0, 0, time.UTC), comments[0].Date.UTC())
+	suite.False(comments[1].Pinned)
+	suite.Equal("bob", comments[1].Author)
+	suite.Equal("Build fails with gcc 14:\nerror: foo", comments[1].Text)
+	suite.Equal("Works, thanks!\n\nsecond paragraph", comments[2].Text)
+	suite.Empty(parseAurComments("<html>no comments</html>"))
+
+	pkg := InfoRecord{LocalBuildDate: int(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).Unix())}
+	suite.False(isNewComment(comments[0], pkg))
+	suite.True(isNewComment(comments[1], pkg))
+	suite.False(isNewComment(comments[1], InfoRecord{}))
+}
+
+func (suite *pacseekTestSuite) TestWatchlist() {
+	suite.T().Setenv("XDG_CONFIG_HOME", suite.T().TempDir())
+
+	w, err := config.LoadWatchlist()
+	suite.Nil(err)
+	suite.Empty(w.Packages)
+	suite.True(w.Toggle(config.WatchedPackage{Name: "foo", Source: "AUR", Version: "1.0-1", Maintainer: "alice"}))
+	suite.True(w.Toggle(config.WatchedPackage{Name: "bar", Source: "extra", Version: "2.0-1"}))
+	suite.True(w.Toggle(config.WatchedPackage{Name: "foo", Source: "extra"}))
+	suite.False(w.Toggle(config.WatchedPackage{Name: "foo", Source: "extra"}))
+	suite.Equal(1, w.Index("bar", "extra"))
+	suite.Equal(-1, w.Index("bar", "AUR"))
+	suite.Nil(w.Save())
+
+	w, err = config.LoadWatchlist()
+	suite.Nil(err)
+	suite.Len(w.Packages, 2)
+	suite.Equal("alice", w.Packages[0].Maintainer)
+
+	old := w.Packages[0]
+	suite.Empty(watchChanges(old, &InfoRecord{Name: "foo", Version: "1.0-1", Maintainer: "alice"}))
+	suite.Equal([]string{"version 1.0-1 -> 1.1-1", "maintainer alice -> mallory", "flagged out-of-date"},
+		watchChanges(old, &InfoRecord{Name: "foo", Version: "1.1-1", Maintainer: "mallory", OutOfDate: 1700000000}))
+	suite.Equal([]string{"orphaned"}, watchChanges(old, &InfoRecord{Name: "foo", Version: "1.0-1"}))
+	suite.Equal([]string{"deleted"}, watchChanges(old, nil))
+
+	deleted := watchedPackage("foo", "AUR", nil)
+	suite.True(deleted.Deleted)
+	suite.Empty(watchChanges(deleted, nil))
+	suite.Equal([]string{"available again", "adopted by bob"}, watchChanges(deleted, &InfoRecord{Name: "foo", Version: "1.2-1", Maintainer: "bob"}))
+	suite.Equal(config.WatchedPackage{Name: "foo", Source: "AUR", Version: "1.2-1", Maintainer: "bob"},
+		watchedPackage("foo", "AUR", &InfoRecord{Name: "foo", Version: "1.2-1", Maintainer: "bob"}))
+
+	// a different packager of a repository package is no change of maintainers
+	repo := w.Packages[1]
+	repo.Maintainer = "Alice <alice@archlinux.org>"
+	suite.Empty(watchChanges(repo, &InfoRecord{Name: "bar", Version: "2.0-1", Maintainer: "Bob <bob@archlinux.org>"}))
+	suite.Equal([]string{"version 2.0-1 -> 2.0-2"}, watchChanges(repo, &InfoRecord{Name: "bar", Version: "2.0-2"}))
+}
@@ -1,6 +1,7 @@
 package pacseek
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -26,8 +27,13 @@ var gitlabRepl = []RegexReplace{
 }
 
 // download the PKGBUILD file
-func getPkgbuildContent(url string) (string, error) {
-	resp, err := http.Get(url)
+func getPkgbuildContent(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -58,5 +64,5 @@ func encodePackageGitlabUrl(pkgname string) string {
 
 // returns command to download and display PKGBUILD
 func (ps *UI) getPkgbuildCommand(source, base string) string {
-	return strings.Replace(ps.conf.ShowPkgbuildCommand, "{url}", getPkgbuildUrl(source, base), -1)
+	return strings.Replace(ps.conf.ShowPkgbuildCommand, "{url}", ps.pkgbuildUrl(source, base), -1)
 }
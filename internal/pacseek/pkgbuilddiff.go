@@ -0,0 +1,240 @@
+package pacseek
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rivo/tview"
+)
+
+// files of an AUR package we keep a history of
+const (
+	filePkgbuild = "PKGBUILD"
+	fileSrcinfo  = ".SRCINFO"
+)
+
+// separates the changes from the PKGBUILD in our PKGBUILD view
+const pkgbuildDiffSeparator = "\n[::b]==> PKGBUILD[::-]\n\n"
+
+// returns the directory holding the PKGBUILD history of a package base ($XDG_CACHE_HOME/pacseek/pkgbuilds/<pkgbase>)
+func pkgbuildHistoryDir(base string) (string, error) {
+	dir, err := util.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, "pkgbuilds", base), nil
+}
+
+// stores the PKGBUILD / .SRCINFO of a package version
+func savePkgbuildSnapshot(base, version, file, content string) error {
+	if base == "" || version == "" || strings.ContainsAny(base+version, "/") {
+		return nil
+	}
+	dir, err := pkgbuildHistoryDir(base)
+	if err != nil {
+		return err
+	}
+	dir = path.Join(dir, version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(dir, file), []byte(content), 0644)
+}
+
+// loads a stored PKGBUILD / .SRCINFO of a package version
+func loadPkgbuildSnapshot(base, version, file string) (string, bool) {
+	dir, err := pkgbuildHistoryDir(base)
+	if err != nil {
+		return "", false
+	}
+	b, err := os.ReadFile(path.Join(dir, version, file))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// returns the version we compare a new PKGBUILD with:
+// the installed version if we know its PKGBUILD, otherwise the version we've looked at last
+func pkgbuildBaseline(base, localVersion, newVersion string) (string, bool) {
+	if _, ok := loadPkgbuildSnapshot(base, localVersion, filePkgbuild); ok {
+		return localVersion, true
+	}
+	dir, err := pkgbuildHistoryDir(base)
+	if err != nil {
+		return "", false
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	var latest time.Time
+	version := ""
+	for _, e := range entries {
+		if e.Name() == newVersion {
+			continue
+		}
+		info, err := os.Stat(path.Join(dir, e.Name(), filePkgbuild))
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+			version = e.Name()
+		}
+	}
+	return version, version != ""
+}
+
+// returns the full version (epoch:pkgver-pkgrel) of a .SRCINFO file
+func srcinfoVersion(srcinfo string) string {
+	values := map[string]string{}
+	for _, line := range strings.Split(srcinfo, "\n") {
+		// the package sections come after pkgbase
+		if strings.HasPrefix(line, "pkgname") {
+			break
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if values["pkgver"] == "" || values["pkgrel"] == "" {
+		return ""
+	}
+	version := values["pkgver"] + "-" + values["pkgrel"]
+	if values["epoch"] != "" && values["epoch"] != "0" {
+		version = values["epoch"] + ":" + version
+	}
+	return version
+}
+
+// stores the PKGBUILD and .SRCINFO of a git repository (e.g. after we've built it)
+func savePkgbuildSnapshotsFrom(repo, base string) error {
+	srcinfo, err := os.ReadFile(path.Join(repo, fileSrcinfo))
+	if err != nil {
+		return err
+	}
+	pkgbuild, err := os.ReadFile(path.Join(repo, filePkgbuild))
+	if err != nil {
+		return err
+	}
+	version := srcinfoVersion(string(srcinfo))
+	if err := savePkgbuildSnapshot(base, version, fileSrcinfo, string(srcinfo)); err != nil {
+		return err
+	}
+	return savePkgbuildSnapshot(base, version, filePkgbuild, string(pkgbuild))
+}
+
+// returns a unified diff of two file versions; empty if they are equal
+func unifiedDiff(old, new, file, oldVersion, newVersion string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(old),
+		B:        splitLines(new),
+		FromFile: file + " " + oldVersion,
+		ToFile:   file + " " + newVersion,
+		Context:  3,
+	})
+}
+
+// splits text into lines, keeping the line breaks
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// colors added / removed lines of a diff for our text views
+func colorDiff(diff string) string {
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		color := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			color = "::b"
+		case strings.HasPrefix(line, "+"):
+			color = "green"
+		case strings.HasPrefix(line, "-"):
+			color = "red"
+		case strings.HasPrefix(line, "@@"):
+			color = "darkcyan"
+		}
+		lines[i] = tview.Escape(line)
+		if color != "" {
+			lines[i] = "[" + color + "]" + lines[i] + "[-:-:-]"
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// returns the content of an AUR file (e.g. .SRCINFO), from cache if possible
+func (ps *UI) getAurFile(ctx context.Context, base, file string) (string, error) {
+	key := "file:AUR/" + base + "/" + file
+	if cached, found := ps.cachePkgbuild.Get(key); found {
+		if content, ok := cached.(string); ok {
+			return content, nil
+		}
+	}
+	content, err := getPkgbuildContent(ctx, fmt.Sprintf(UrlAurFile, file, base))
+	if err != nil {
+		return "", err
+	}
+	if !ps.conf.DisableCache {
+		ps.cachePkgbuild.Set(key, content, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+	}
+	return content, nil
+}
+
+// remembers the PKGBUILD of an AUR package and returns the changes since the installed / last viewed version.
+// The diff is empty if there is no upgrade pending or we don't know an older version
+func (ps *UI) pkgbuildChanges(ctx context.Context, pkg InfoRecord, pkgbuild string) (diff, since string, err error) {
+	if pkg.Source != "AUR" {
+		return "", "", nil
+	}
+	// the selected record might come from our cache
+	pkg = ps.withInstallState(pkg)
+	since, ok := pkgbuildBaseline(pkg.PackageBase, pkg.LocalVersion, pkg.Version)
+	if err = savePkgbuildSnapshot(pkg.PackageBase, pkg.Version, filePkgbuild, pkgbuild); err != nil {
+		return "", "", err
+	}
+	if !ok || pkg.LocalVersion == "" || pkg.LocalVersion == pkg.Version {
+		return "", "", nil
+	}
+
+	// the .SRCINFO is only of interest if there is an upgrade pending
+	srcinfo, err := ps.getAurFile(ctx, pkg.PackageBase, fileSrcinfo)
+	if err != nil {
+		return "", "", err
+	}
+	if err = savePkgbuildSnapshot(pkg.PackageBase, pkg.Version, fileSrcinfo, srcinfo); err != nil {
+		return "", "", err
+	}
+
+	for file, content := range map[string]string{filePkgbuild: pkgbuild, fileSrcinfo: srcinfo} {
+		old, ok := loadPkgbuildSnapshot(pkg.PackageBase, since, file)
+		if !ok {
+			continue
+		}
+		d, err := unifiedDiff(old, content, file, since, pkg.Version)
+		if err != nil {
+			return "", "", err
+		}
+		if file == filePkgbuild {
+			diff = d + diff
+		} else {
+			diff += d
+		}
+	}
+	if diff == "" {
+		diff = fmt.Sprintf("No changes since %s\n", since)
+	}
+	return diff, since, nil
+}
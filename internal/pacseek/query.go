@@ -0,0 +1,168 @@
+package pacseek
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/moson-mo/pacseek/internal/args"
+	"github.com/moson-mo/pacseek/internal/config"
+)
+
+// exit codes for query mode
+const (
+	QueryFound    = 0
+	QueryNotFound = 1
+	QueryError    = 2
+)
+
+// Query runs a non-interactive query (search, info, upgrades) and prints the results as JSON.
+// It returns the exit code for the process
+func Query(conf *config.Settings, flags args.Flags, stdout, stderr io.Writer) int {
+	h, err := initPacmanDbs(conf.PacmanDbPath, conf.PacmanConfigPath, flags.Repositories)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return QueryError
+	}
+	defer h.Release()
+
+	// we don't need any of the tview components here
+	ps := &UI{
+		conf:        conf,
+		alpmHandle:  h,
+		locker:      &sync.RWMutex{},
+		dumpLocker:  &sync.Mutex{},
+		filesLocker: &sync.Mutex{},
+		filterRepos: flags.Repositories,
+	}
+	if err = ps.initSources(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return QueryError
+	}
+	return ps.query(flags, stdout, stderr)
+}
+
+// runs a query with our package sources and prints the results as JSON; it returns the exit code for the process.
+// When a source fails (search / info), the results of the others are printed nevertheless.
+// Finding nothing is an error then, the package might exist in the failed source
+func (ps *UI) query(flags args.Flags, stdout, stderr io.Writer) int {
+	var results any
+	found := 0
+	failed := false
+	ctx := context.Background()
+
+	switch flags.Command {
+	case "search":
+		if len(flags.CommandArgs) != 1 {
+			fmt.Fprintln(stderr, "search requires exactly one search-term")
+			return QueryError
+		}
+		term := normalizeSearchTerm(flags.CommandArgs[0], ps.conf.SearchMode)
+		packages, errs := ps.searchSources(ctx, term)
+		if len(errs) > 0 {
+			fmt.Fprintln(stderr, errors.Join(errs...))
+			failed = true
+		}
+		// we rank by the search-term without qualifiers
+		if q, err := parseSearchQuery(term); err == nil {
+			term = q.Term
+		}
+		sortPackages(packages, term, ps.conf.SearchMode)
+		if len(packages) > ps.conf.MaxResults {
+			packages = packages[:ps.conf.MaxResults]
+		}
+		results, found = packages, len(packages)
+	case "info":
+		if len(flags.CommandArgs) == 0 {
+			fmt.Fprintln(stderr, "info requires at least one package name")
+			return QueryError
+		}
+		infos := ps.queryInfo(ctx, flags.CommandArgs...)
+		if infos.Error != "" {
+			fmt.Fprintln(stderr, infos.Error)
+			failed = true
+		}
+		results, found = infos.Results, len(infos.Results)
+	case "upgrades":
+		up, err := ps.findUpgrades(ctx)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return QueryError
+		}
+		results, found = up, len(up)
+	default:
+		fmt.Fprintln(stderr, "unknown command: "+flags.Command)
+		return QueryError
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(results); err != nil {
+		fmt.Fprintln(stderr, err)
+		return QueryError
+	}
+
+	if found == 0 && failed {
+		return QueryError
+	}
+	if found == 0 {
+		return QueryNotFound
+	}
+	return QueryFound
+}
+
+// retrieves package information from all sources. Locally installed packages are only returned if no other source knows them.
+// Errors of the sources are collected, so that a failing source does not hide the results of the others
+func (ps *UI) queryInfo(ctx context.Context, pkgs ...string) SearchResults {
+	sr := SearchResults{
+		Results: []InfoRecord{},
+	}
+	errs := []string{}
+	localOnly := map[string]InfoRecord{}
+	missing := pkgs
+	for _, s := range ps.sources {
+		if len(missing) == 0 {
+			break
+		}
+		r := s.Info(ctx, missing...)
+		if r.Error != "" {
+			errs = append(errs, r.Error)
+		}
+
+		resolved := map[string]bool{}
+		for _, info := range r.Results {
+			if info.Source == "local" {
+				info.Description = strings.TrimSuffix(info.Description, localOnlyNote)
+				localOnly[info.Name] = info
+				continue
+			}
+			resolved[info.Name] = true
+			sr.Results = append(sr.Results, info)
+		}
+
+		next := []string{}
+		for _, pkg := range missing {
+			if !resolved[pkg] {
+				next = append(next, pkg)
+			}
+		}
+		missing = next
+	}
+	for _, pkg := range missing {
+		if info, ok := localOnly[pkg]; ok {
+			sr.Results = append(sr.Results, info)
+		}
+	}
+
+	sr.Error = strings.Join(errs, "\n")
+
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+	addInstallState(ps.alpmHandle, sr.Results...)
+	addLocalSatisfiers(ps.alpmHandle, sr.Results...)
+	return sr
+}
@@ -0,0 +1,182 @@
+package pacseek
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// queuedPackage is a package that is marked for installation / removal
+type queuedPackage struct {
+	Name      string
+	Source    string
+	Installed bool
+}
+
+// returns the position of a package in our queue
+func (ps *UI) queueIndex(name, source string) int {
+	for i, q := range ps.queue {
+		if q.Name == name && q.Source == source {
+			return i
+		}
+	}
+	return -1
+}
+
+// adds the selected package to the queue or removes it if it's queued already
+func (ps *UI) toggleQueueSelected() {
+	row, _ := ps.tablePackages.GetSelection()
+	pkg, ok := ps.packageAt(row)
+	if !ok {
+		return
+	}
+
+	if i := ps.queueIndex(pkg.Name, pkg.Source); i != -1 {
+		ps.queue = append(ps.queue[:i], ps.queue[i+1:]...)
+	} else {
+		ps.queue = append(ps.queue, queuedPackage{
+			Name:      pkg.Name,
+			Source:    pkg.Source,
+			Installed: pkg.IsInstalled,
+		})
+	}
+	ps.applyQueuedStyle(row)
+	ps.drawQueue()
+
+	// move on to the next package
+	if row < ps.tablePackages.GetRowCount()-1 {
+		ps.tablePackages.Select(row+1, 0)
+	}
+}
+
+// highlights the package name of queued packages
+func (ps *UI) applyQueuedStyle(row int) {
+	pkg, ok := ps.packageAt(row)
+	col := ps.columnIndex("Name")
+	if !ok || col == -1 {
+		return
+	}
+	cell := ps.tablePackages.GetCell(row, col)
+	if ps.queueIndex(pkg.Name, pkg.Source) != -1 {
+		cell.SetTextColor(ps.conf.Colors().Accent)
+	} else {
+		cell.SetTextColor(tcell.ColorWhite)
+	}
+}
+
+// draws the list of queued packages and shows / hides the queue box
+func (ps *UI) drawQueue() {
+	ps.tableQueue.Clear().
+		SetTitle(fmt.Sprintf(" [::b]Queue (%d) - CTRL+X: Apply ", len(ps.queue)))
+
+	for i, q := range ps.queue {
+		q := q
+		action := "[green::b]+[-::-] "
+		if q.Installed {
+			action = "[red::b]-[-::-] "
+		}
+		color := ps.conf.Colors().PackagelistSourceRepository
+		if q.Source == "AUR" {
+			color = ps.conf.Colors().PackagelistSourceAUR
+		}
+		ps.tableQueue.SetCell(i, 0, &tview.TableCell{
+			Text:            action + q.Name,
+			Color:           tcell.ColorWhite,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+			Clicked: func() bool {
+				if i := ps.queueIndex(q.Name, q.Source); i != -1 {
+					ps.queue = append(ps.queue[:i], ps.queue[i+1:]...)
+				}
+				ps.drawQueue()
+				ps.refreshQueuedStyles()
+				return true
+			},
+		}).
+			SetCell(i, 1, &tview.TableCell{
+				Text:            q.Source,
+				Color:           color,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+			})
+	}
+
+	height := 0
+	if len(ps.queue) > 0 {
+		height = len(ps.queue) + 2
+		if height > 10 {
+			height = 10
+		}
+	}
+	ps.flexLeft.ResizeItem(ps.tableQueue, height, 0)
+}
+
+// re-applies the queued style for all rows of the package list
+func (ps *UI) refreshQueuedStyles() {
+	for i := 1; i < ps.tablePackages.GetRowCount(); i++ {
+		ps.applyQueuedStyle(i)
+	}
+}
+
+// installs / removes all queued packages with as few commands as possible
+func (ps *UI) applyQueue() {
+	if len(ps.queue) == 0 {
+		return
+	}
+
+	remove := []InfoRecord{}
+	install := []InfoRecord{}
+	installAur := []InfoRecord{}
+	separateAur := ps.conf.AurBuildInternally || ps.conf.AurUseDifferentCommands && ps.conf.AurInstallCommand != ""
+
+	for _, q := range ps.queue {
+		pkg := ps.queuedPackageInfo(q)
+		switch {
+		case q.Installed:
+			remove = append(remove, pkg)
+		case q.Source == "AUR" && separateAur:
+			installAur = append(installAur, pkg)
+		default:
+			install = append(install, pkg)
+		}
+	}
+
+	commands := []string{}
+	if len(remove) > 0 {
+		commands = append(commands, composeCommand(ps.conf.UninstallCommand, remove...))
+	}
+	if len(install) > 0 {
+		commands = append(commands, composeCommand(ps.conf.InstallCommand, install...))
+	}
+	if len(installAur) > 0 && !ps.conf.AurBuildInternally {
+		commands = append(commands, composeCommand(ps.conf.AurInstallCommand, installAur...))
+	}
+
+	ps.previewTransaction(append(install, installAur...), remove, func() {
+		if len(commands) > 0 {
+			ps.runCommand(ps.shell, "-c", strings.Join(commands, " && "))
+		}
+
+		ps.queue = []queuedPackage{}
+		ps.drawQueue()
+		ps.updateInstalledState()
+		ps.refreshQueuedStyles()
+
+		// AUR packages are built in the background
+		if len(installAur) > 0 && ps.conf.AurBuildInternally {
+			ps.buildAur(installAur, nil)
+		}
+	})
+}
+
+// returns the package information for a queued package (from cache if possible)
+func (ps *UI) queuedPackageInfo(q queuedPackage) InfoRecord {
+	if cached, found := ps.cacheInfo.Get(q.Name + "-" + q.Source); found {
+		return cached.(InfoRecord)
+	}
+	return InfoRecord{
+		Name:        q.Name,
+		Source:      q.Source,
+		PackageBase: q.Name,
+	}
+}
@@ -0,0 +1,242 @@
+package pacseek
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/gdamore/tcell/v2"
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/rivo/tview"
+)
+
+// matches the files in a cgit tree listing, e.g. <a class='ls-blob install' href='...'>yay.install</a>
+var cgitBlobRegex = regexp.MustCompile(`<a class='ls-blob[^']*' href='[^']*'>([^<]+)</a>`)
+
+// an entry of a GitLab repository tree listing
+type gitlabTreeEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// checks if we can browse the git repository of a package
+func hasRepoFiles(source string) bool {
+	return source == "AUR" || util.SliceContains(getArchRepos(), source)
+}
+
+// composes the URL listing the files of a package's git repository
+func repoFilesUrl(source, base string) string {
+	if util.SliceContains(getArchRepos(), source) {
+		return fmt.Sprintf(UrlRepoTree, encodePackageGitlabUrl(base))
+	}
+	return fmt.Sprintf(UrlAurTree, base)
+}
+
+// composes the URL to a file of a package's git repository
+func repoFileUrl(source, base, file string) string {
+	if file == filePkgbuild {
+		return getPkgbuildUrl(source, base)
+	}
+	if util.SliceContains(getArchRepos(), source) {
+		return fmt.Sprintf(UrlRepoFile, encodePackageGitlabUrl(base), file)
+	}
+	return fmt.Sprintf(UrlAurFile, file, base)
+}
+
+// parses the files of a cgit tree listing (the top-level directory only)
+func parseCgitTree(page string) []string {
+	files := []string{}
+	for _, m := range cgitBlobRegex.FindAllStringSubmatch(page, -1) {
+		files = append(files, html.UnescapeString(m[1]))
+	}
+	return sortRepoFiles(files)
+}
+
+// parses the files of a (recursive) GitLab tree listing
+func parseGitlabTree(data []byte) ([]string, error) {
+	entries := []gitlabTreeEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if e.Type == "blob" {
+			files = append(files, e.Path)
+		}
+	}
+	return sortRepoFiles(files), nil
+}
+
+// sorts files by name with the PKGBUILD on top
+func sortRepoFiles(files []string) []string {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i] == filePkgbuild || files[j] == filePkgbuild {
+			return files[i] == filePkgbuild
+		}
+		return files[i] < files[j]
+	})
+	return files
+}
+
+// returns the first install script of a package (scriptlets run as root)
+func installScript(files []string) (string, bool) {
+	for _, f := range files {
+		if isInstallScript(f) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// checks if a file is an install script
+func isInstallScript(file string) bool {
+	return strings.HasSuffix(file, ".install")
+}
+
+// returns the name of the chroma lexer for a file
+func repoFileLexer(file string) string {
+	name := file[strings.LastIndex(file, "/")+1:]
+	switch {
+	case isInstallScript(name):
+		return "bash"
+	case name == fileSrcinfo:
+		return "ini"
+	}
+	if lexer := lexers.Match(name); lexer != nil {
+		return strings.ToLower(lexer.Config().Name)
+	}
+	return "plaintext"
+}
+
+// returns the files of a package's git repository, from cache if possible
+func (ps *UI) getRepoFiles(ctx context.Context, source, base string) ([]string, error) {
+	key := "files:" + source + "/" + base
+	if cached, found := ps.cachePkgbuild.Get(key); found {
+		if files, ok := cached.([]string); ok {
+			return files, nil
+		}
+	}
+	content, err := getPkgbuildContent(ctx, repoFilesUrl(source, base))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if source == "AUR" {
+		files = parseCgitTree(content)
+	} else if files, err = parseGitlabTree([]byte(content)); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no files found for " + base)
+	}
+	if !ps.conf.DisableCache {
+		ps.cachePkgbuild.Set(key, files, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+	}
+	return files, nil
+}
+
+// returns the content of a file of a package's git repository, from cache if possible
+func (ps *UI) getRepoFile(ctx context.Context, source, base, file string) (string, error) {
+	if source == "AUR" {
+		return ps.getAurFile(ctx, base, file)
+	}
+	key := "file:" + source + "/" + base + "/" + file
+	if cached, found := ps.cachePkgbuild.Get(key); found {
+		if content, ok := cached.(string); ok {
+			return content, nil
+		}
+	}
+	content, err := getPkgbuildContent(ctx, repoFileUrl(source, base, file))
+	if err != nil {
+		return "", err
+	}
+	if !ps.conf.DisableCache {
+		ps.cachePkgbuild.Set(key, content, time.Duration(ps.conf.CacheExpiry)*time.Minute)
+	}
+	return content, nil
+}
+
+// draws the list of files below the PKGBUILD
+func (ps *UI) drawRepoFiles(files []string) {
+	ps.repoFiles = files
+	ps.tableRepoFiles.Clear().
+		SetTitle(fmt.Sprintf(" [::b]%sFiles (%d) ", ps.conf.Glyphs().Package, len(files)))
+	for i, f := range files {
+		ps.tableRepoFiles.SetCell(i, 0, &tview.TableCell{
+			Text:            tview.Escape(f),
+			Color:           ps.conf.Colors().PackagelistHeader,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+			Reference:       f,
+		})
+		if isInstallScript(f) {
+			ps.tableRepoFiles.SetCell(i, 1, &tview.TableCell{
+				Text:            "install script - runs as root (press i)",
+				Color:           tcell.ColorRed,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+				Expansion:       1000,
+			})
+		}
+	}
+	ps.tableRepoFiles.Select(0, 0).
+		ScrollToBeginning()
+
+	height := min(len(files), 6) + 2
+	ps.flexRight.AddItem(ps.tableRepoFiles, height, 0, false)
+}
+
+// shows a file of the selected package's git repository in our PKGBUILD view
+func (ps *UI) displayRepoFile(file string) {
+	if ps.selectedPackage == nil {
+		return
+	}
+	if file == filePkgbuild {
+		ps.displayPkgbuild()
+		return
+	}
+	pkg := *ps.selectedPackage
+
+	// findings refer to the lines of the PKGBUILD
+	ps.flexRight.RemoveItem(ps.tableFindings)
+	ps.textPkgbuild.Clear().
+		SetTitle(" [::b]Loading " + tview.Escape(file) + "... ")
+
+	ps.jobs.run(jobPkgbuild, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		content, err := ps.getRepoFile(ctx, pkg.Source, pkg.PackageBase, file)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ps.textPkgbuild.SetTitle(" [::b]Error loading " + tview.Escape(file) + " ")
+				ps.textPkgbuild.SetText(err.Error())
+				return
+			}
+			ps.drawRepoFile(content, file, pkg.Name)
+		})
+	})
+}
+
+// shows the install script of the selected package
+func (ps *UI) displayInstallScript() {
+	file, ok := installScript(ps.repoFiles)
+	if !ok {
+		ps.tableRepoFiles.SetTitle(" [::b]" + ps.conf.Glyphs().Package + "Files - no install script ")
+		return
+	}
+	for row := 0; row < ps.tableRepoFiles.GetRowCount(); row++ {
+		if ps.tableRepoFiles.GetCell(row, 0).Reference == file {
+			ps.tableRepoFiles.Select(row, 0)
+		}
+	}
+	ps.displayRepoFile(file)
+}
@@ -0,0 +1,239 @@
+package pacseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// revDepInfo is an installed package with the packages depending on it
+type revDepInfo struct {
+	Explicit    bool
+	RequiredBy  []string
+	OptionalFor []string
+}
+
+// revDepNode is a package in a reverse dependency tree
+type revDepNode struct {
+	Name     string
+	Explicit bool
+	Optional bool // the parent is an optional dependency of this package
+	Cycle    bool
+	Repeated bool // the package is expanded elsewhere in the tree
+	Children []*revDepNode
+
+	parent *revDepNode
+}
+
+// builds a tree of the packages that (indirectly) depend on a package, up to the explicitly installed ones
+func buildRevDepTree(name string, lookup func(string) (revDepInfo, bool)) *revDepNode {
+	info, _ := lookup(name)
+	root := &revDepNode{Name: name, Explicit: info.Explicit}
+	expanded := map[string]bool{name: true}
+
+	var expand func(n *revDepNode, info revDepInfo)
+	expand = func(n *revDepNode, info revDepInfo) {
+		add := func(names []string, optional bool) {
+			for _, name := range names {
+				cinfo, ok := lookup(name)
+				if !ok {
+					continue
+				}
+				child := &revDepNode{
+					Name:     name,
+					Explicit: cinfo.Explicit,
+					Optional: optional,
+					parent:   n,
+				}
+				n.Children = append(n.Children, child)
+
+				switch {
+				case child.inPath(name):
+					child.Cycle = true
+				case expanded[name]:
+					child.Repeated = true
+				case !cinfo.Explicit:
+					// explicitly installed packages are our roots
+					expanded[name] = true
+					expand(child, cinfo)
+				}
+			}
+		}
+		add(info.RequiredBy, false)
+		add(info.OptionalFor, true)
+	}
+	expand(root, info)
+	return root
+}
+
+// checks if a package is one of the ancestors of a node
+func (n *revDepNode) inPath(name string) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the explicitly installed packages that depend on a package (not counting optional dependencies).
+// These would have to be removed along with it
+func revDepExplicit(name string, lookup func(string) (revDepInfo, bool)) []string {
+	explicit := []string{}
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		info, ok := lookup(queue[0])
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		for _, r := range info.RequiredBy {
+			if seen[r] {
+				continue
+			}
+			seen[r] = true
+			queue = append(queue, r)
+			if rinfo, ok := lookup(r); ok && rinfo.Explicit {
+				explicit = append(explicit, r)
+			}
+		}
+	}
+	sort.Strings(explicit)
+	return explicit
+}
+
+// returns a lookup function for installed packages; results are kept since computing them is quite expensive
+func localRevDepLookup(local alpm.IDB) func(string) (revDepInfo, bool) {
+	known := map[string]revDepInfo{}
+	return func(name string) (revDepInfo, bool) {
+		if info, ok := known[name]; ok {
+			return info, true
+		}
+		pkg := local.Pkg(name)
+		if pkg == nil {
+			return revDepInfo{}, false
+		}
+		info := revDepInfo{
+			Explicit:    pkg.Reason() == alpm.PkgReasonExplicit,
+			RequiredBy:  pkg.ComputeRequiredBy(),
+			OptionalFor: pkg.ComputeOptionalFor(),
+		}
+		known[name] = info
+		return info, true
+	}
+}
+
+// displays the packages that (indirectly) depend on the selected package
+func (ps *UI) displayRevDepTree() {
+	if ps.selectedPackage == nil {
+		return
+	}
+	name := ps.selectedPackage.Name
+
+	loading := tview.NewTreeNode("Resolving reverse dependencies...")
+	ps.reverseDeps = true
+	ps.treeDeps.SetRoot(loading).
+		SetCurrentNode(loading).
+		SetTitle(" [::b]" + ps.conf.Glyphs().Package + "Required by - " + name + " ")
+	ps.flexRight.Clear().
+		AddItem(ps.treeDeps, 0, 1, true)
+	ps.app.SetFocus(ps.treeDeps)
+
+	ps.jobs.run(jobDeps, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		tree, removable, err := ps.getRevDepTree(name)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ps.treeDeps.SetRoot(tview.NewTreeNode(err.Error()).SetColor(tcell.ColorRed))
+				return
+			}
+			ps.drawRevDepTree(tree, removable)
+		})
+	})
+}
+
+// returns the reverse dependency tree of an installed package and the explicit packages depending on it
+func (ps *UI) getRevDepTree(name string) (*revDepNode, []string, error) {
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+
+	local, err := ps.alpmHandle.LocalDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	if local.Pkg(name) == nil {
+		return nil, nil, errors.New(name + " is not installed")
+	}
+	lookup := localRevDepLookup(local)
+	return buildRevDepTree(name, lookup), revDepExplicit(name, lookup), nil
+}
+
+// draws a reverse dependency tree
+func (ps *UI) drawRevDepTree(tree *revDepNode, removable []string) {
+	var add func(n *revDepNode) *tview.TreeNode
+	add = func(n *revDepNode) *tview.TreeNode {
+		node := tview.NewTreeNode(revDepNodeText(n)).
+			SetReference(n)
+		for _, c := range n.Children {
+			node.AddChild(add(c))
+		}
+		return node
+	}
+	root := add(tree)
+
+	why := "installed explicitly"
+	if !tree.Explicit {
+		switch {
+		case len(removable) > 0:
+			why = "needed by " + strings.Join(removable, ", ")
+		case len(tree.Children) > 0:
+			why = "only needed optionally"
+		default:
+			why = "[yellow]not needed by any package[-]"
+		}
+	}
+	if len(removable) > 0 {
+		root.AddChild(tview.NewTreeNode(fmt.Sprintf("Removing %s would also remove %d explicitly installed package(s): %s",
+			tree.Name, len(removable), strings.Join(removable, ", "))).
+			SetColor(ps.conf.Colors().PackagelistHeader).
+			SetSelectable(false))
+	}
+
+	ps.treeDeps.SetRoot(root).
+		SetCurrentNode(root).
+		SetTitle(" [::b]" + ps.conf.Glyphs().Package + "Required by - " + tree.Name + "[::-] - " + why + " ")
+}
+
+// composes the text of a tree node
+func revDepNodeText(n *revDepNode) string {
+	text := "[::b]" + tview.Escape(n.Name) + "[::-]"
+	notes := []string{}
+	if n.Explicit {
+		notes = append(notes, "[green]explicit[-]")
+	}
+	if n.Optional {
+		notes = append(notes, "optional")
+	}
+	switch {
+	case n.Cycle:
+		notes = append(notes, "[yellow]cycle[-]")
+	case n.Repeated:
+		notes = append(notes, "expanded elsewhere")
+	}
+	if len(notes) > 0 {
+		text += " - " + strings.Join(notes, ", ")
+	}
+	return text
+}
@@ -0,0 +1,141 @@
+package pacseek
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// returns the available search modes
+func searchModes() []string {
+	return []string{"StartsWith", "Contains", "Regex", "Fuzzy"}
+}
+
+// prepares the text entered by the user for searching
+func normalizeSearchTerm(text, mode string) string {
+	// lower-casing would change the meaning of regular expressions (e.g. \S vs. \s)
+	if mode == "Regex" {
+		return text
+	}
+	return strings.ToLower(text)
+}
+
+// packageMatcher checks package names and descriptions against a search-term
+type packageMatcher struct {
+	name        func(string) bool
+	description func(string) bool
+	by          string
+}
+
+// creates a matcher for the given search-term, search mode and search by option
+func newPackageMatcher(term, mode, by string) (*packageMatcher, error) {
+	m := &packageMatcher{by: by}
+	switch mode {
+	case "Contains":
+		m.name = func(s string) bool { return strings.Contains(s, term) }
+	case "Regex":
+		re, err := regexp.Compile("(?i)" + term)
+		if err != nil {
+			return nil, errors.New("invalid regular expression: " + err.Error())
+		}
+		m.name = re.MatchString
+	case "Fuzzy":
+		m.name = func(s string) bool { return fuzzy.Match(term, s) }
+		// fuzzy matching long texts would match pretty much anything
+		m.description = func(s string) bool { return strings.Contains(s, term) }
+	default:
+		m.name = func(s string) bool { return strings.HasPrefix(s, term) }
+	}
+	if m.description == nil {
+		m.description = m.name
+	}
+	return m, nil
+}
+
+// checks if a package matches
+func (m *packageMatcher) matches(name, description string) bool {
+	return m.name(name) ||
+		(m.by == "Name & Description" && m.description(strings.ToLower(description)))
+}
+
+// returns the term we send to the AUR; results are filtered afterwards with our matcher
+func aurSearchTerm(term, mode string) (string, error) {
+	var aurTerm string
+	switch mode {
+	case "Regex":
+		re, err := syntax.Parse(term, syntax.Perl)
+		if err != nil {
+			return "", errors.New("invalid regular expression: " + err.Error())
+		}
+		aurTerm = longestLiteral(re.Simplify())
+	case "Fuzzy":
+		// the characters of a fuzzy term might be spread across the name, so we use its longest "word"
+		for _, word := range strings.FieldsFunc(term, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(word) > len(aurTerm) {
+				aurTerm = word
+			}
+		}
+	default:
+		return term, nil
+	}
+	if len(aurTerm) < 2 {
+		return "", errors.New("can't search the AUR: search-term needs to contain at least 2 consecutive letters")
+	}
+	return strings.ToLower(aurTerm), nil
+}
+
+// returns the longest literal string that is part of every match of a regular expression
+func longestLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return longestLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return longestLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		longest := ""
+		for _, sub := range re.Sub {
+			if lit := longestLiteral(sub); len(lit) > len(longest) {
+				longest = lit
+			}
+		}
+		return longest
+	}
+	return ""
+}
+
+// sorts packages by name or, in fuzzy mode, by how well they match the search-term
+func sortPackages(packages []Package, term, mode string) {
+	if mode != "Fuzzy" {
+		sort.Slice(packages, func(i, j int) bool {
+			return packages[i].Name < packages[j].Name
+		})
+		return
+	}
+
+	rank := func(name string) int {
+		r := fuzzy.RankMatch(term, name)
+		if r < 0 {
+			// matched by description only
+			return int(^uint(0) >> 1)
+		}
+		return r
+	}
+	sort.SliceStable(packages, func(i, j int) bool {
+		ri, rj := rank(packages[i].Name), rank(packages[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+		return packages[i].Name < packages[j].Name
+	})
+}
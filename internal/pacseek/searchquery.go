@@ -0,0 +1,293 @@
+package pacseek
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// searchQuery is a search-term with optional qualifiers like "repo:extra" or "-git"
+type searchQuery struct {
+	Term    string
+	Filters []queryFilter
+}
+
+// queryFilter is a single qualifier of a search query
+type queryFilter struct {
+	Field  string
+	Value  string
+	Negate bool
+}
+
+// fields that can be used as qualifiers
+var queryFields = []string{"repo", "aur", "installed", "maintainer", "comaintainers", "submitter", "provides", "depends",
+	"makedepends", "optdepends", "checkdepends", "keywords", "license", "outofdate", "votes"}
+
+// search by options (besides name and description) and the fields they look up
+var searchByFields = map[string]string{
+	"Provides":       "provides",
+	"Depends":        "depends",
+	"MakeDepends":    "makedepends",
+	"OptDepends":     "optdepends",
+	"CheckDepends":   "checkdepends",
+	"Maintainer":     "maintainer",
+	"Co-Maintainers": "comaintainers",
+	"Submitter":      "submitter",
+	"Keywords":       "keywords",
+}
+
+// returns the available search by options
+func searchByOptions() []string {
+	return []string{"Name", "Name & Description", "Provides", "Depends", "MakeDepends", "OptDepends", "CheckDepends",
+		"Maintainer", "Co-Maintainers", "Submitter", "Keywords", "Files"}
+}
+
+// parses a search-term like "qt -git repo:extra votes:>10"
+func parseSearchQuery(text string) (searchQuery, error) {
+	q := searchQuery{}
+	terms := []string{}
+
+	for _, word := range strings.Fields(text) {
+		negate := false
+		if len(word) > 1 && word[0] == '-' {
+			negate = true
+			word = word[1:]
+		}
+
+		field, value, isFilter := strings.Cut(word, ":")
+		// things like "(?:x)" or "std::" are part of the term
+		if isFilter && strings.IndexFunc(field, func(r rune) bool { return !unicode.IsLetter(r) }) != -1 {
+			isFilter = false
+			if negate {
+				word = "-" + word
+				negate = false
+			}
+		}
+		if !isFilter {
+			if negate {
+				q.Filters = append(q.Filters, queryFilter{Field: "name", Value: word, Negate: true})
+			} else {
+				terms = append(terms, word)
+			}
+			continue
+		}
+
+		field = strings.ToLower(field)
+		switch field {
+		case "aur":
+			if value == "" {
+				value = "yes"
+			}
+		case "installed", "outofdate":
+			if value != "yes" && value != "no" {
+				return q, fmt.Errorf("%s: needs to be \"yes\" or \"no\"", field)
+			}
+		case "votes":
+			if _, _, err := parseComparison(value); err != nil {
+				return q, err
+			}
+		case "repo", "maintainer", "comaintainers", "submitter", "provides", "depends",
+			"makedepends", "optdepends", "checkdepends", "keywords", "license":
+			if value == "" {
+				return q, fmt.Errorf("%s: needs a value", field)
+			}
+		default:
+			return q, fmt.Errorf("unknown qualifier \"%s:\" (available: %s)", field, strings.Join(queryFields, ", "))
+		}
+		q.Filters = append(q.Filters, queryFilter{Field: field, Value: value, Negate: negate})
+	}
+	q.Term = strings.Join(terms, " ")
+
+	if q.Term == "" && len(q.Filters) == 0 {
+		return q, errors.New("search-term is empty")
+	}
+	return q, nil
+}
+
+// turns the search-term into a filter when searching by a field like "Provides"
+func (q *searchQuery) applySearchBy(by string) {
+	if field, ok := searchByFields[by]; ok && q.Term != "" {
+		q.Filters = append(q.Filters, queryFilter{Field: field, Value: q.Term})
+		q.Term = ""
+	}
+}
+
+// parses comparisons like ">100", "<=5" or "10"
+func parseComparison(value string) (string, int, error) {
+	op := "="
+	for _, o := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, o) {
+			op = o
+			value = value[len(o):]
+			break
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return "", 0, fmt.Errorf("votes: \"%s\" is not a number", value)
+	}
+	return op, n, nil
+}
+
+// checks if a query might return packages from a source (e.g. "AUR" or a repository name)
+func (q searchQuery) allowsSource(aur bool) bool {
+	for _, f := range q.Filters {
+		var ok bool
+		switch f.Field {
+		case "aur":
+			ok = aur == (f.Value == "yes")
+		case "repo":
+			// a positive repo filter rules out the AUR, unless it's the AUR itself
+			if f.Negate || !aur {
+				continue
+			}
+			ok = strings.EqualFold(f.Value, "aur")
+		default:
+			continue
+		}
+		if ok == f.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// checks if the query has filters that need package details which aren't part of search results
+func (q searchQuery) needsDetails() bool {
+	for _, f := range q.Filters {
+		switch f.Field {
+		case "maintainer", "votes", "outofdate", "name", "repo", "aur", "installed":
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// returns the first (positive) filter that can be used for a server-side lookup by field
+func (q searchQuery) lookupFilter() (queryFilter, bool) {
+	for _, f := range q.Filters {
+		if f.Negate {
+			continue
+		}
+		switch f.Field {
+		case "maintainer", "comaintainers", "submitter", "provides", "depends",
+			"makedepends", "optdepends", "checkdepends", "keywords":
+			return f, true
+		}
+	}
+	return queryFilter{}, false
+}
+
+// removes the first occurrence of a filter
+func removeFilter(filters []queryFilter, f queryFilter) []queryFilter {
+	for i := range filters {
+		if filters[i] == f {
+			return append(filters[:i:i], filters[i+1:]...)
+		}
+	}
+	return filters
+}
+
+// checks the filters against a package record; "installed:" is checked by matchesPackage
+func (q searchQuery) matches(r InfoRecord) bool {
+	for _, f := range q.Filters {
+		var ok bool
+		switch f.Field {
+		case "name":
+			ok = strings.Contains(r.Name, f.Value)
+		case "repo":
+			ok = strings.EqualFold(r.Source, f.Value)
+		case "aur":
+			ok = (r.Source == "AUR") == (f.Value == "yes")
+		case "maintainer":
+			ok = strings.Contains(strings.ToLower(r.Maintainer), strings.ToLower(f.Value))
+		case "comaintainers":
+			ok = containsFold(r.CoMaintainers, f.Value)
+		case "submitter":
+			ok = strings.EqualFold(r.Submitter, f.Value)
+		case "keywords":
+			ok = containsFold(r.Keywords, f.Value)
+		case "provides":
+			ok = r.Name == f.Value || containsDependency(r.Provides, f.Value)
+		case "depends":
+			ok = containsDependency(r.Depends, f.Value)
+		case "makedepends":
+			ok = containsDependency(r.MakeDepends, f.Value)
+		case "optdepends":
+			ok = containsDependency(r.OptDepends, f.Value)
+		case "checkdepends":
+			ok = containsDependency(r.CheckDepends, f.Value)
+		case "license":
+			for _, l := range r.License {
+				if strings.Contains(strings.ToLower(l), strings.ToLower(f.Value)) {
+					ok = true
+					break
+				}
+			}
+		case "outofdate":
+			ok = (r.OutOfDate > 0) == (f.Value == "yes")
+		case "votes":
+			op, n, _ := parseComparison(f.Value)
+			switch op {
+			case ">":
+				ok = r.NumVotes > n
+			case ">=":
+				ok = r.NumVotes >= n
+			case "<":
+				ok = r.NumVotes < n
+			case "<=":
+				ok = r.NumVotes <= n
+			default:
+				ok = r.NumVotes == n
+			}
+		default:
+			continue
+		}
+		if ok == f.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// checks the filters that apply to our package list entries
+func (q searchQuery) matchesPackage(p Package) bool {
+	for _, f := range q.Filters {
+		var ok bool
+		switch f.Field {
+		case "installed":
+			ok = p.IsInstalled == (f.Value == "yes")
+		case "name":
+			ok = strings.Contains(p.Name, f.Value)
+		default:
+			continue
+		}
+		if ok == f.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// checks if a list of dependencies (e.g. "java-runtime=17") contains a package name
+func containsDependency(deps []string, name string) bool {
+	for _, dep := range deps {
+		if depName(dep) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if a list contains a value (case-insensitive)
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package pacseek
 import (
 	"fmt"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -29,7 +30,19 @@ func (ps *UI) createComponents() {
 	ps.formSettings = tview.NewForm()
 	ps.textMessage = tview.NewTextView()
 	ps.textPkgbuild = tview.NewTextView()
+	ps.textFiles = tview.NewTextView()
+	ps.treeDeps = tview.NewTreeView()
+	ps.flexBuild = tview.NewFlex().SetDirection(tview.FlexRow)
+	ps.textBuild = tview.NewTextView()
+	ps.formBuild = tview.NewForm()
+	ps.tableFindings = tview.NewTable()
+	ps.tableRepoFiles = tview.NewTable()
+	ps.tableHistory = tview.NewTable()
+	ps.textHistory = tview.NewTextView()
+	ps.textComments = tview.NewTextView()
+	ps.tableWatch = tview.NewTable()
 	ps.tableNews = tview.NewTable()
+	ps.tableQueue = tview.NewTable()
 
 	// component config
 	ps.flexRoot.SetBorder(true).
@@ -41,8 +54,8 @@ func (ps *UI) createComponents() {
 	}
 	ps.tableDetails.SetEvaluateAllRows(true).
 		SetFocusFunc(func() {
-			if ps.flexRight.GetItem(0) == ps.textPkgbuild {
-				ps.app.SetFocus(ps.textPkgbuild)
+			if item := ps.flexRight.GetItem(0); item == ps.textPkgbuild || item == ps.textFiles || item == ps.treeDeps || item == ps.flexBuild || item == ps.tableHistory || item == ps.textComments || item == ps.tableWatch {
+				ps.app.SetFocus(item)
 			} else if !ps.tableDetailsMore {
 				ps.app.SetFocus(ps.tablePackages)
 			}
@@ -69,6 +82,75 @@ func (ps *UI) createComponents() {
 		SetTitleAlign(tview.AlignLeft).
 		SetBorderPadding(1, 1, 1, 1)
 	ps.pkgbuildWriter = tview.ANSIWriter(ps.textPkgbuild)
+	ps.textFiles.SetWrap(false).
+		SetDynamicColors(true).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft).
+		SetBorderPadding(1, 1, 1, 1)
+	ps.treeDeps.SetSelectedFunc(func(node *tview.TreeNode) {
+		node.SetExpanded(!node.IsExpanded())
+	}).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft).
+		SetBorderPadding(1, 1, 1, 1)
+	ps.tableFindings.SetSelectable(true, false).
+		SetSelectedFunc(func(row, column int) {
+			// scroll to the line of a finding
+			if line, ok := ps.tableFindings.GetCell(row, 0).Reference.(int); ok {
+				ps.textPkgbuild.ScrollTo(max(line-2, 0), 0)
+			}
+		}).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft)
+	ps.tableRepoFiles.SetSelectable(true, false).
+		SetSelectedFunc(func(row, column int) {
+			if file, ok := ps.tableRepoFiles.GetCell(row, 0).Reference.(string); ok {
+				ps.displayRepoFile(file)
+			}
+		}).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft)
+	ps.tableHistory.SetSelectable(true, false).
+		SetSelectedFunc(func(row, column int) {
+			if c, ok := ps.tableHistory.GetCell(row, 0).Reference.(gitCommit); ok {
+				ps.displayCommit(c)
+			}
+		}).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft)
+	ps.textHistory.SetWrap(false).
+		SetDynamicColors(true).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft).
+		SetBorderPadding(0, 0, 1, 1)
+	ps.textComments.SetDynamicColors(true).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft).
+		SetBorderPadding(1, 1, 1, 1)
+	ps.tableWatch.SetSelectable(true, false).
+		SetFixed(1, 0).
+		SetSelectedFunc(func(row, column int) {
+			// search for a watched package
+			if w, ok := ps.tableWatch.GetCell(row, 0).Reference.(config.WatchedPackage); ok {
+				ps.inputSearch.SetText(w.Name)
+				ps.displayPackages(w.Name)
+				ps.app.SetFocus(ps.tablePackages)
+			}
+		}).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft)
+	ps.textBuild.SetDynamicColors(true).
+		SetChangedFunc(func() {
+			ps.app.Draw()
+		})
+	ps.buildWriter = tview.ANSIWriter(ps.textBuild)
+	ps.formBuild.SetButtonsAlign(tview.AlignCenter).
+		SetInputCapture(ps.buildInputCapture)
+	ps.flexBuild.AddItem(ps.textBuild, 0, 1, false).
+		AddItem(ps.formBuild, 3, 0, true).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft).
+		SetBorderPadding(1, 0, 1, 1)
 	ps.tableNews.SetSelectable(false, false).
 		SetFocusFunc(func() {
 			ps.app.SetFocus(ps.inputSearch)
@@ -76,6 +158,12 @@ func (ps *UI) createComponents() {
 		SetBorderPadding(1, 1, 1, 1).
 		SetBorder(true).
 		SetTitleAlign(tview.AlignLeft)
+	ps.tableQueue.SetSelectable(false, false).
+		SetFocusFunc(func() {
+			ps.app.SetFocus(ps.tablePackages)
+		}).
+		SetBorder(true).
+		SetTitleAlign(tview.AlignLeft)
 	ps.tableDetails.SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
 		// draw "more..." for package details exceeding screen limits
 		_, _, _, innerHeight := ps.tableDetails.GetInnerRect()
@@ -98,7 +186,8 @@ func (ps *UI) createComponents() {
 	ps.flexContainer.AddItem(ps.flexLeft, 0, ps.leftProportion, true).
 		AddItem(ps.flexRight, 0, 10-ps.leftProportion, false)
 	ps.flexLeft.AddItem(ps.flexTopLeft, 3, 1, true).
-		AddItem(ps.tablePackages, 0, 1, false)
+		AddItem(ps.tablePackages, 0, 1, false).
+		AddItem(ps.tableQueue, 0, 0, false)
 	ps.flexTopLeft.AddItem(ps.inputSearch, 0, 1, true).
 		AddItem(ps.spinner, 3, 1, false)
 	ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
@@ -113,7 +202,24 @@ func (ps *UI) applyColors() {
 	ps.inputSearch.SetFieldBackgroundColor(ps.conf.Colors().SearchBar).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
 	ps.inputSearch.SetAutocompleteStyles(ps.conf.Colors().SettingsDropdownNotSelected, tcell.StyleDefault, tcell.StyleDefault.Reverse(true))
 	ps.textPkgbuild.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.textFiles.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.treeDeps.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.flexBuild.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.textBuild.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.formBuild.SetButtonBackgroundColor(ps.conf.Colors().SettingsFieldBackground).
+		SetButtonTextColor(ps.conf.Colors().SettingsFieldText).
+		SetFieldBackgroundColor(ps.conf.Colors().SettingsFieldBackground).
+		SetFieldTextColor(ps.conf.Colors().SettingsFieldText).
+		SetLabelColor(ps.conf.Colors().SettingsFieldLabel).
+		SetBackgroundColor(ps.conf.Colors().DefaultBackground)
 	ps.tableNews.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.tableQueue.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.tableFindings.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.tableRepoFiles.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.tableHistory.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.textHistory.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.textComments.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+	ps.tableWatch.SetTitleColor(ps.conf.Colors().Title).SetBackgroundColor(ps.conf.Colors().DefaultBackground)
 	ps.tablePackages.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
 	ps.tablePackages.SetSelectedStyle(tcell.StyleDefault.Reverse(true))
 	ps.spinner.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
@@ -129,25 +235,14 @@ func (ps *UI) applyColors() {
 	// package list
 	ps.drawPackageListHeader(ps.conf.PackageColumnWidth)
 	for i := 1; i < ps.tablePackages.GetRowCount(); i++ {
-		// Package
-		c := ps.tablePackages.GetCell(i, 0)
-		c.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
-
-		// Source
-		c = ps.tablePackages.GetCell(i, 1)
-		col := ps.conf.Colors().PackagelistSourceRepository
-		if c.Text == "AUR" {
-			col = ps.conf.Colors().PackagelistSourceAUR
-		}
-		c.SetTextColor(col)
-		c.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
-
-		// Installed
-		c = ps.tablePackages.GetCell(i, 2)
-		c.SetTextColor(ps.conf.Colors().DefaultBackground)
-		c.SetText(ps.getInstalledStateText(c.Reference.(bool)))
+		if pkg, ok := ps.packageAt(i); ok {
+			ps.drawPackageListRow(i, pkg, ps.conf.PackageColumnWidth)
+		}
 	}
 
+	// queue
+	ps.drawQueue()
+
 	// details
 	if ps.selectedPackage != nil {
 		ps.drawPackageInfo(*ps.selectedPackage, ps.width)
@@ -156,7 +251,7 @@ func (ps *UI) applyColors() {
 
 // apply drop-down colors
 func (ps *UI) applyDropDownColors() {
-	for _, title := range []string{"Search mode: ", "Search by: ", "Color scheme: ", "Border style: ", "Glyph style: "} {
+	for _, title := range []string{"Search mode: ", "Search by: ", "AUR data source: ", "Color scheme: ", "Border style: ", "Glyph style: "} {
 		if dd, ok := ps.formSettings.GetFormItemByLabel(title).(*tview.DropDown); ok {
 			dd.SetListStyles(tcell.StyleDefault.Background(ps.conf.Colors().SettingsDropdownNotSelected).Foreground(ps.conf.Colors().SettingsFieldText),
 				tcell.StyleDefault.Background(ps.conf.Colors().SettingsFieldText).Foreground(ps.conf.Colors().SettingsDropdownNotSelected))
@@ -197,9 +292,8 @@ func (ps *UI) applyGlyphStyle() {
 
 	// package list
 	for i := 1; i < ps.tablePackages.GetRowCount(); i++ {
-		c := ps.tablePackages.GetCell(i, 2)
-		if ref, ok := c.Reference.(bool); ok {
-			c.SetText(ps.getInstalledStateText(ref))
+		if pkg, ok := ps.packageAt(i); ok {
+			ps.drawPackageListRow(i, pkg, ps.conf.PackageColumnWidth)
 		}
 	}
 }
@@ -217,12 +311,22 @@ func (ps *UI) setupKeyBindings() {
 
 	// app / global
 	ps.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// dialogs handle their keys themselves
+		if ps.dialogVisible {
+			return event
+		}
 		settingsVisible := ps.flexRight.GetItem(0) == ps.formSettings
 		pkgbuildVisible := ps.flexRight.GetItem(0) == ps.textPkgbuild
+		filesVisible := ps.flexRight.GetItem(0) == ps.textFiles
+		depsVisible := ps.flexRight.GetItem(0) == ps.treeDeps
+		buildVisible := ps.flexRight.GetItem(0) == ps.flexBuild
+		historyVisible := ps.flexRight.GetItem(0) == ps.tableHistory
+		commentsVisible := ps.flexRight.GetItem(0) == ps.textComments
+		watchVisible := ps.flexRight.GetItem(0) == ps.tableWatch
 
 		// CTRL+Q / ESC - Quit
 		if event.Key() == tcell.KeyCtrlQ ||
-			(event.Key() == tcell.KeyEscape && !settingsVisible && !pkgbuildVisible && !ps.conf.EnableAutoSuggest) {
+			(event.Key() == tcell.KeyEscape && !settingsVisible && !pkgbuildVisible && !filesVisible && !depsVisible && !buildVisible && !historyVisible && !commentsVisible && !watchVisible && !ps.conf.EnableAutoSuggest) {
 			if !ps.settingsChanged {
 				if ps.conf.SaveWindowLayout {
 					ps.conf.LeftProportion = ps.leftProportion
@@ -287,8 +391,9 @@ func (ps *UI) setupKeyBindings() {
 
 		// CTRL+W - Wipe cache
 		if event.Key() == tcell.KeyCtrlW {
-			ps.cacheSearch.Flush()
-			ps.cacheInfo.Flush()
+			if err := ps.wipeCaches(); err != nil {
+				ps.displayMessage(err.Error(), true)
+			}
 			return nil
 		}
 
@@ -310,6 +415,107 @@ func (ps *UI) setupKeyBindings() {
 			}
 		}
 
+		// CTRL+F - Show files of the selected (installed) package
+		if event.Key() == tcell.KeyCtrlF ||
+			event.Key() == tcell.KeyEscape && filesVisible {
+			if ps.selectedPackage != nil {
+				if filesVisible {
+					ps.flexRight.Clear()
+					ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
+					ps.app.SetFocus(ps.tablePackages)
+				} else {
+					ps.displayFiles()
+				}
+			}
+			return nil
+		}
+
+		// CTRL+Y / CTRL+V - Show dependency tree / reverse dependency tree of the selected package
+		if event.Key() == tcell.KeyCtrlY || event.Key() == tcell.KeyCtrlV ||
+			event.Key() == tcell.KeyEscape && depsVisible {
+			reverse := event.Key() == tcell.KeyCtrlV
+			if ps.selectedPackage != nil {
+				if depsVisible && (event.Key() == tcell.KeyEscape || reverse == ps.reverseDeps) {
+					ps.flexRight.Clear()
+					ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
+					ps.app.SetFocus(ps.tablePackages)
+				} else if reverse {
+					ps.displayRevDepTree()
+				} else {
+					ps.displayDepTree()
+				}
+			}
+			return nil
+		}
+
+		// CTRL+E - Show / hide the output of our AUR build; the search field uses it to jump to the end of the line
+		if event.Key() == tcell.KeyCtrlE && !ps.inputSearch.HasFocus() ||
+			event.Key() == tcell.KeyEscape && buildVisible {
+			if buildVisible {
+				ps.flexRight.Clear()
+				ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
+				ps.app.SetFocus(ps.tablePackages)
+			} else if ps.formBuild.GetButtonCount() > 0 {
+				ps.showBuild()
+			}
+			return nil
+		}
+
+		// CTRL+K - Show git history of the selected package; the search field uses it to delete to the end of the line
+		if event.Key() == tcell.KeyCtrlK && !ps.inputSearch.HasFocus() ||
+			event.Key() == tcell.KeyEscape && historyVisible {
+			if ps.selectedPackage != nil {
+				if historyVisible {
+					ps.flexRight.Clear()
+					ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
+					ps.app.SetFocus(ps.tablePackages)
+				} else {
+					ps.displayHistory()
+				}
+			}
+			return nil
+		}
+
+		// CTRL+Z - Show comments of the selected AUR package
+		if event.Key() == tcell.KeyCtrlZ ||
+			event.Key() == tcell.KeyEscape && commentsVisible {
+			if ps.selectedPackage != nil {
+				if commentsVisible {
+					ps.flexRight.Clear()
+					ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
+					ps.app.SetFocus(ps.tablePackages)
+				} else {
+					ps.displayComments()
+				}
+			}
+			return nil
+		}
+
+		// ALT+W - Show watchlist
+		if (event.Key() == tcell.KeyRune && event.Rune() == 'w' && event.Modifiers() == tcell.ModAlt) ||
+			event.Key() == tcell.KeyEscape && watchVisible {
+			if watchVisible {
+				ps.flexRight.Clear()
+				ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
+				ps.app.SetFocus(ps.tablePackages)
+			} else {
+				ps.displayWatchlist()
+			}
+			return nil
+		}
+
+		// CTRL+D - Switch install reason (explicit / dependency)
+		if event.Key() == tcell.KeyCtrlD {
+			ps.toggleInstallReason()
+			return nil
+		}
+
+		// CTRL+X - Install / remove queued packages
+		if event.Key() == tcell.KeyCtrlX {
+			ps.applyQueue()
+			return nil
+		}
+
 		// CTRL+O - Open URL for selected package
 		if event.Key() == tcell.KeyCtrlO && ps.selectedPackage != nil {
 			exec.Command("xdg-open", ps.selectedPackage.URL).Start()
@@ -318,7 +524,7 @@ func (ps *UI) setupKeyBindings() {
 
 		// CTRL+G - Upgradable packages
 		if event.Key() == tcell.KeyCtrlG {
-			if pkgbuildVisible || settingsVisible {
+			if pkgbuildVisible || settingsVisible || filesVisible || depsVisible || buildVisible || historyVisible || commentsVisible || watchVisible {
 				ps.flexRight.Clear()
 				ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
 			}
@@ -328,7 +534,7 @@ func (ps *UI) setupKeyBindings() {
 
 		// CTRL+L - Locally installed packages
 		if event.Key() == tcell.KeyCtrlL {
-			if pkgbuildVisible {
+			if pkgbuildVisible || filesVisible || depsVisible || buildVisible || historyVisible || commentsVisible || watchVisible {
 				ps.flexRight.Clear()
 				ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
 			}
@@ -336,6 +542,22 @@ func (ps *UI) setupKeyBindings() {
 			return nil
 		}
 
+		// CTRL+T - Orphaned packages
+		if event.Key() == tcell.KeyCtrlT {
+			if pkgbuildVisible || filesVisible || depsVisible || buildVisible || historyVisible || commentsVisible || watchVisible {
+				ps.flexRight.Clear()
+				ps.flexRight.AddItem(ps.tableDetails, 0, 1, false)
+			}
+			ps.displayOrphans()
+			return nil
+		}
+
+		// CTRL+R - Remove orphaned packages (while they are being shown)
+		if event.Key() == tcell.KeyCtrlR && ps.orphansShown {
+			ps.removeOrphans()
+			return nil
+		}
+
 		// Shift+Left - decrease size of left container
 		if event.Key() == tcell.KeyLeft && event.Modifiers() == tcell.ModShift {
 			if ps.leftProportion != 1 {
@@ -370,7 +592,7 @@ func (ps *UI) setupKeyBindings() {
 	// ENTER / TAB
 	ps.inputSearch.SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEnter {
-			ps.lastSearchTerm = strings.ToLower(ps.inputSearch.GetText())
+			ps.lastSearchTerm = normalizeSearchTerm(ps.inputSearch.GetText(), ps.conf.SearchMode)
 			if len(ps.lastSearchTerm) == 0 {
 				ps.displayInstalled(false)
 				return
@@ -414,7 +636,7 @@ func (ps *UI) setupKeyBindings() {
 			if itemRight == ps.formSettings {
 				ps.app.SetFocus(ps.formSettings.GetFormItem(0))
 			} else if (itemRight == ps.tableDetails && ps.tableDetailsMore) ||
-				(itemRight == ps.formSettings || itemRight == ps.textPkgbuild) {
+				(itemRight == ps.formSettings || itemRight == ps.textPkgbuild || itemRight == ps.textFiles || itemRight == ps.treeDeps || itemRight == ps.flexBuild || itemRight == ps.tableHistory || itemRight == ps.textComments || itemRight == ps.tableWatch) {
 				ps.app.SetFocus(itemRight)
 			} else {
 				ps.app.SetFocus(ps.inputSearch)
@@ -441,6 +663,16 @@ func (ps *UI) setupKeyBindings() {
 			ps.installSelectedPackage()
 			return nil
 		}
+		// SPACE - add to / remove from queue
+		if event.Rune() == ' ' {
+			ps.toggleQueueSelected()
+			return nil
+		}
+		// w - add to / remove from watchlist
+		if event.Rune() == 'w' {
+			ps.toggleWatchSelected()
+			return nil
+		}
 		// Down / j / k -> noop: WTF? Prevent lock-up with empty list ;) :(
 		// upstream issue?
 		if (event.Key() == tcell.KeyDown || event.Rune() == 'k' || event.Rune() == 'j') &&
@@ -449,7 +681,7 @@ func (ps *UI) setupKeyBindings() {
 		}
 
 		// sorting keys
-		if util.SliceContains([]rune{'N', 'S', 'I', 'M', 'P'}, event.Rune()) {
+		if _, ok := packageColumnByKey(event.Rune()); ok {
 			ps.sortAndRedrawPackageList(event.Rune())
 			return nil
 		}
@@ -465,8 +697,8 @@ func (ps *UI) setupKeyBindings() {
 		ps.tablePackages.SetTitle(fmt.Sprintf(" (%d/%d) ", row, ps.tablePackages.GetRowCount()-1))
 	})
 
-	// PKGBUILD
-	ps.textPkgbuild.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+	// PKGBUILD / Files / Dependencies
+	textInputCapture := func(event *tcell.EventKey) *tcell.EventKey {
 		// CTRL+Left
 		if event.Key() == tcell.KeyLeft && event.Modifiers() == tcell.ModCtrl {
 			ps.app.SetFocus(ps.tablePackages)
@@ -479,7 +711,52 @@ func (ps *UI) setupKeyBindings() {
 		}
 
 		return event
+	}
+	pkgbuildInputCapture := func(event *tcell.EventKey) *tcell.EventKey {
+		// TAB - next part of our PKGBUILD view (files / findings)
+		if event.Key() == tcell.KeyTAB {
+			ps.focusNextItem()
+			return nil
+		}
+		// i - install script
+		if event.Rune() == 'i' && len(ps.repoFiles) > 0 {
+			ps.displayInstallScript()
+			return nil
+		}
+		return textInputCapture(event)
+	}
+	ps.textPkgbuild.SetInputCapture(pkgbuildInputCapture)
+	ps.tableRepoFiles.SetInputCapture(pkgbuildInputCapture)
+	ps.tableFindings.SetInputCapture(pkgbuildInputCapture)
+	historyInputCapture := func(event *tcell.EventKey) *tcell.EventKey {
+		// TAB - list of commits -> changes of a commit
+		if event.Key() == tcell.KeyTAB {
+			ps.focusNextItem()
+			return nil
+		}
+		return textInputCapture(event)
+	}
+	ps.tableHistory.SetInputCapture(historyInputCapture)
+	ps.textHistory.SetInputCapture(historyInputCapture)
+	ps.textFiles.SetInputCapture(textInputCapture)
+	ps.textComments.SetInputCapture(textInputCapture)
+	ps.tableWatch.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// w - remove from watchlist
+		if event.Rune() == 'w' {
+			row, _ := ps.tableWatch.GetSelection()
+			if w, ok := ps.tableWatch.GetCell(row, 0).Reference.(config.WatchedPackage); ok {
+				ps.watchlist.Toggle(w)
+				delete(ps.watchChanges, w.Source+"/"+w.Name)
+				if err := ps.watchlist.Save(); err != nil {
+					ps.displayMessage(err.Error(), true)
+				}
+				ps.drawWatchlist()
+			}
+			return nil
+		}
+		return textInputCapture(event)
 	})
+	ps.treeDeps.SetInputCapture(textInputCapture)
 
 	// Package details
 	ps.tableDetails.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
@@ -539,6 +816,20 @@ func (ps *UI) saveSettings(defaults bool) {
 					ps.displayMessage("Can't convert delay value to int", true)
 					return
 				}
+			case "AUR dump URL: ":
+				ps.conf.AurDumpUrl = txt
+			case "AUR dump refresh (h): ":
+				refresh, err := strconv.Atoi(txt)
+				if err != nil {
+					ps.displayMessage("Can't convert dump refresh value to int", true)
+					return
+				}
+				// we'd download the dump with every search otherwise
+				if refresh < 1 {
+					ps.displayMessage("AUR dump refresh must be at least 1 hour", true)
+					return
+				}
+				ps.conf.AurDumpRefresh = refresh
 			case "Pacman DB path: ":
 				ps.conf.PacmanDbPath = txt
 			case "Pacman config path: ":
@@ -547,6 +838,8 @@ func (ps *UI) saveSettings(defaults bool) {
 				ps.conf.InstallCommand = txt
 			case "Uninstall command: ":
 				ps.conf.UninstallCommand = txt
+			case "Install reason command: ":
+				ps.conf.InstallReasonCommand = txt
 			case "AUR Install command: ":
 				ps.conf.AurInstallCommand = txt
 			case "Upgrade command: ":
@@ -581,6 +874,21 @@ func (ps *UI) saveSettings(defaults bool) {
 					ps.displayMessage("Can't convert package column width value to int", true)
 					return
 				}
+			case "Package list columns: ":
+				columns, err := packageListColumns(strings.Split(txt, ","))
+				if err != nil {
+					ps.displayMessage(err.Error(), true)
+					return
+				}
+				names := []string{}
+				for _, c := range columns {
+					names = append(names, c.Name)
+				}
+				if !slices.Equal(names, ps.conf.PackageListColumns) {
+					ps.conf.PackageListColumns = names
+					ps.columns = columns
+					ps.drawPackageListContent(ps.shownPackages, ps.conf.PackageColumnWidth)
+				}
 			}
 		} else if dd, ok := item.(*tview.DropDown); ok {
 			_, opt := dd.GetCurrentOption()
@@ -589,6 +897,8 @@ func (ps *UI) saveSettings(defaults bool) {
 				ps.conf.SearchMode = opt
 			case "Search by: ":
 				ps.conf.SearchBy = opt
+			case "AUR data source: ":
+				ps.conf.AurDataSource = opt
 			case "Color scheme: ":
 				ps.conf.ColorScheme = opt
 			case "Border style: ":
@@ -602,6 +912,8 @@ func (ps *UI) saveSettings(defaults bool) {
 				ps.conf.DisableAur = cb.IsChecked()
 			case "Disable Cache: ":
 				ps.conf.DisableCache = cb.IsChecked()
+			case "Persist cache on disk: ":
+				ps.conf.EnableDiskCache = cb.IsChecked()
 			case "Separate AUR commands: ":
 				ps.conf.AurUseDifferentCommands = cb.IsChecked()
 			case "Show PKGBUILD internally: ":
@@ -623,6 +935,14 @@ func (ps *UI) saveSettings(defaults bool) {
 				}
 			case "Separate Deps with Newline: ":
 				ps.conf.SepDepsWithNewLine = cb.IsChecked()
+			case "Build AUR packages internally: ":
+				ps.conf.AurBuildInternally = cb.IsChecked()
+			case "Clean build: ":
+				ps.conf.AurCleanBuild = cb.IsChecked()
+			case "Disable PKGBUILD linter: ":
+				ps.conf.DisablePkgbuildLinter = cb.IsChecked()
+			case "Disable transaction preview: ":
+				ps.conf.DisableTransactionPreview = cb.IsChecked()
 			}
 		}
 	}
@@ -631,6 +951,11 @@ func (ps *UI) saveSettings(defaults bool) {
 		ps.displayMessage(err.Error(), true)
 		return
 	}
+	err = ps.initSources()
+	if err != nil {
+		ps.displayMessage(err.Error(), true)
+		return
+	}
 	msg := "Settings have been applied / saved"
 	if defaults {
 		msg = "Default settings have been restored"
@@ -641,4 +966,9 @@ func (ps *UI) saveSettings(defaults bool) {
 	if ps.conf.DisableCache {
 		ps.cacheInfo.Flush()
 	}
+	if ps.conf.DisableCache || !ps.conf.EnableDiskCache {
+		if err = wipeDiskCache(); err != nil {
+			ps.displayMessage(err.Error(), true)
+		}
+	}
 }
@@ -0,0 +1,279 @@
+package pacseek
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/moson-mo/pacseek/internal/util"
+)
+
+// PackageSource is a backend that can be searched for packages
+type PackageSource interface {
+	// Name returns the name of the source as used in the configuration
+	Name() string
+	// Handles checks if packages with the given source (e.g. repository name) belong to this source
+	Handles(source string) bool
+	// Search returns packages matching the search-term. Packages with source "local" are only shown if no other source found them
+	Search(ctx context.Context, term string) ([]Package, error)
+	// Info returns detailed information for a list of packages
+	Info(ctx context.Context, pkgs ...string) SearchResults
+	// Suggest returns package names for auto-completion
+	Suggest(ctx context.Context, term string) []string
+	// PkgbuildUrl returns the URL of the PKGBUILD file for a package base
+	PkgbuildUrl(source, base string) string
+}
+
+// sourceConstructors holds all available package sources by name
+var sourceConstructors = map[string]func(ps *UI) PackageSource{}
+
+// registers a package source so that it can be enabled in the config file
+func registerSource(name string, constructor func(ps *UI) PackageSource) {
+	sourceConstructors[name] = constructor
+}
+
+func init() {
+	registerSource("Repositories", func(ps *UI) PackageSource { return &repoSource{ps: ps} })
+	registerSource("AUR", func(ps *UI) PackageSource { return &aurSource{ps: ps} })
+}
+
+// creates the list of package sources from our configuration
+func (ps *UI) initSources() error {
+	sources := []PackageSource{}
+	for _, name := range ps.conf.PackageSources {
+		constructor, ok := sourceConstructors[name]
+		if !ok {
+			return fmt.Errorf("unknown package source: %s", name)
+		}
+		if name == "AUR" && ps.conf.DisableAur {
+			continue
+		}
+		sources = append(sources, constructor(ps))
+	}
+	ps.sources = sources
+	return nil
+}
+
+// returns the package source that handles a given source / repository name
+func (ps *UI) sourceFor(source string) PackageSource {
+	for _, s := range ps.sources {
+		if s.Handles(source) {
+			return s
+		}
+	}
+	return nil
+}
+
+// repoSource searches the pacman sync DB's and the local DB
+type repoSource struct {
+	ps *UI
+}
+
+func (s *repoSource) Name() string {
+	return "Repositories"
+}
+
+func (s *repoSource) Handles(source string) bool {
+	if source == "local" {
+		return true
+	}
+	s.ps.locker.Lock()
+	defer s.ps.locker.Unlock()
+	if s.ps.alpmHandle == nil {
+		return false
+	}
+	dbs, err := s.ps.alpmHandle.SyncDBs()
+	if err != nil {
+		return false
+	}
+	for _, db := range dbs.Slice() {
+		if db.Name() == source {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *repoSource) Search(ctx context.Context, term string) ([]Package, error) {
+	if s.ps.conf.SearchBy == "Files" {
+		return s.ps.searchFiles(ctx, term)
+	}
+	s.ps.locker.Lock()
+	defer s.ps.locker.Unlock()
+	packages, localPackages, err := searchRepos(s.ps.alpmHandle, term, s.ps.conf.SearchMode, s.ps.conf.SearchBy, s.ps.conf.MaxResults)
+	return append(packages, localPackages...), err
+}
+
+func (s *repoSource) Info(ctx context.Context, pkgs ...string) SearchResults {
+	s.ps.locker.Lock()
+	defer s.ps.locker.Unlock()
+	return infoPacman(s.ps.alpmHandle, s.ps.conf.ComputeRequiredBy, pkgs...)
+}
+
+func (s *repoSource) Suggest(ctx context.Context, term string) []string {
+	s.ps.locker.Lock()
+	defer s.ps.locker.Unlock()
+	return suggestRepos(s.ps.alpmHandle, term)
+}
+
+func (s *repoSource) PkgbuildUrl(source, base string) string {
+	return getPkgbuildUrl(source, base)
+}
+
+// aurSource queries the AUR RPC interface
+type aurSource struct {
+	ps *UI
+}
+
+func (s *aurSource) Name() string {
+	return "AUR"
+}
+
+func (s *aurSource) Handles(source string) bool {
+	return source == "AUR"
+}
+
+func (s *aurSource) Search(ctx context.Context, term string) ([]Package, error) {
+	// there are no file lists for AUR packages
+	if s.ps.conf.SearchBy == "Files" {
+		return []Package{}, nil
+	}
+
+	var packages []Package
+	var err error
+
+	dump, derr := s.dump(ctx)
+	if derr == nil {
+		packages, err = dump.search(term, s.ps.conf.SearchMode, s.ps.conf.SearchBy, s.ps.conf.MaxResults)
+	} else if s.ps.conf.AurDataSource == aurSourceDump {
+		return []Package{}, derr
+	} else {
+		packages, err = searchAur(ctx, s.ps.conf.AurRpcUrl, term, s.ps.conf.AurTimeout, s.ps.conf.SearchMode, s.ps.conf.SearchBy, s.ps.conf.MaxResults)
+	}
+
+	s.ps.locker.Lock()
+	defer s.ps.locker.Unlock()
+	addPackageInstallState(s.ps.alpmHandle, packages)
+	return packages, err
+}
+
+func (s *aurSource) Info(ctx context.Context, pkgs ...string) SearchResults {
+	dump, err := s.dump(ctx)
+	if err == nil {
+		sr, notFound := dump.info(pkgs...)
+		if len(notFound) > 0 && s.ps.conf.AurDataSource == aurSourceDumpFallback {
+			sr.Results = append(sr.Results, infoAur(ctx, s.ps.conf.AurRpcUrl, s.ps.conf.AurTimeout, notFound...).Results...)
+		}
+		return sr
+	} else if s.ps.conf.AurDataSource == aurSourceDump {
+		return SearchResults{Error: err.Error()}
+	}
+	return infoAur(ctx, s.ps.conf.AurRpcUrl, s.ps.conf.AurTimeout, pkgs...)
+}
+
+func (s *aurSource) Suggest(ctx context.Context, term string) []string {
+	dump, err := s.dump(ctx)
+	if err == nil {
+		return dump.suggest(term)
+	} else if s.ps.conf.AurDataSource == aurSourceDump {
+		return []string{}
+	}
+	return suggestAur(ctx, s.ps.conf.AurRpcUrl, term, s.ps.conf.AurTimeout)
+}
+
+// returns the local AUR index if it's enabled and available.
+// While it is being downloaded, we only wait for it if there is no other index and no RPC fallback
+func (s *aurSource) dump(ctx context.Context) (*aurDump, error) {
+	if s.ps.conf.AurDataSource != aurSourceDump && s.ps.conf.AurDataSource != aurSourceDumpFallback {
+		return nil, errors.New("AUR dump is disabled")
+	}
+	refresh := time.Duration(s.ps.conf.AurDumpRefresh) * time.Hour
+
+	s.ps.dumpLocker.Lock()
+	if s.ps.aurDump == nil || s.ps.aurDump.url != s.ps.conf.AurDumpUrl || s.ps.aurDump.refresh != refresh {
+		s.ps.aurDump = newAurDump(s.ps.conf.AurDumpUrl, refresh)
+	}
+	dump := s.ps.aurDump
+	s.ps.dumpLocker.Unlock()
+
+	done := dump.update()
+	if done != nil && dump.ready() != nil && s.ps.conf.AurDataSource == aurSourceDump {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := dump.ready(); err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+func (s *aurSource) PkgbuildUrl(source, base string) string {
+	return getPkgbuildUrl(source, base)
+}
+
+// searches all package sources and merges the results
+func (ps *UI) searchSources(ctx context.Context, term string) ([]Package, []error) {
+	packages := []Package{}
+	localPackages := []Package{}
+	errs := []error{}
+
+	// check the query once, so we don't get the same error from each source
+	q, err := parseSearchQuery(term)
+	if err != nil {
+		return packages, []error{err}
+	}
+
+	for _, s := range ps.sources {
+		found, err := s.Search(ctx, term)
+		if err != nil && ctx.Err() == nil {
+			errs = append(errs, err)
+		}
+		for _, pkg := range found {
+			if !q.matchesPackage(pkg) {
+				continue
+			}
+			if pkg.Source == "local" {
+				localPackages = append(localPackages, pkg)
+			} else {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	// add local-only (not found in any other source)
+	for _, lpkg := range localPackages {
+		found := false
+		for _, pkg := range packages {
+			if pkg.Name == lpkg.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			packages = append(packages, lpkg)
+		}
+	}
+
+	return packages, errs
+}
+
+// returns auto-complete suggestions from all package sources
+func (ps *UI) suggestSources(ctx context.Context, term string) []string {
+	suggestions := [][]string{}
+	for _, s := range ps.sources {
+		suggestions = append(suggestions, s.Suggest(ctx, term))
+	}
+	return util.UniqueStrings(suggestions...)
+}
+
+// returns the PKGBUILD URL for a package
+func (ps *UI) pkgbuildUrl(source, base string) string {
+	if s := ps.sourceFor(source); s != nil {
+		return s.PkgbuildUrl(source, base)
+	}
+	return getPkgbuildUrl(source, base)
+}
@@ -2,8 +2,15 @@ package pacseek
 
 import "time"
 
-// starts the spinner
+// starts the spinner (if it's not running already)
 func (ps *UI) startSpinner() {
+	ps.spinLocker.Lock()
+	defer ps.spinLocker.Unlock()
+	ps.spinCount++
+	if ps.spinCount > 1 {
+		return
+	}
+
 	chars := "⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏"
 	if ps.asciiMode {
 		chars = "|/-\\"
@@ -26,7 +33,12 @@ func (ps *UI) startSpinner() {
 	}()
 }
 
-// stops the spinner
+// stops the spinner once all running jobs have finished
 func (ps *UI) stopSpinner() {
-	ps.quitSpin <- true
+	ps.spinLocker.Lock()
+	defer ps.spinLocker.Unlock()
+	ps.spinCount--
+	if ps.spinCount == 0 {
+		ps.quitSpin <- true
+	}
 }
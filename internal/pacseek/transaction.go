@@ -0,0 +1,324 @@
+package pacseek
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jguer/go-alpm/v2"
+	"github.com/gdamore/tcell/v2"
+	"github.com/moson-mo/pacseek/internal/util"
+	"github.com/rivo/tview"
+)
+
+// transaction describes what an install / remove command would do
+type transaction struct {
+	Install      []InfoRecord // packages that were selected for installation
+	Deps         []InfoRecord // dependencies that would be installed along with them
+	Remove       []string     // packages that were selected for removal
+	Cascade      []string     // dependencies that would be removed along with them (e.g. "pacman -Rs")
+	Missing      []string     // dependencies that can't be satisfied
+	Conflicts    []string
+	Breaks       []string // installed packages that require a package that is being removed
+	DownloadSize int64
+	InstallSize  int64
+	FreedSize    int64
+	Aur          bool // sizes of AUR packages are unknown
+}
+
+// adds packages to be installed along with their dependency trees
+func (t *transaction) addInstalls(trees []*depNode) {
+	names := map[string]bool{}
+	for _, tree := range trees {
+		names[tree.Name] = true
+		t.Install = append(t.Install, tree.Record)
+		t.addSizes(tree.Record, tree.Installed)
+	}
+	for _, tree := range trees {
+		s := tree.summary()
+		for _, r := range s.New {
+			if names[r.Name] {
+				continue
+			}
+			names[r.Name] = true
+			t.Deps = append(t.Deps, r)
+			t.addSizes(r, false)
+		}
+		t.Missing = appendUnique(t.Missing, s.Missing...)
+		t.Conflicts = appendUnique(t.Conflicts, s.Conflicts...)
+	}
+}
+
+// adds the sizes of a package that is being installed
+func (t *transaction) addSizes(r InfoRecord, installed bool) {
+	if r.Source == "AUR" {
+		t.Aur = true
+		return
+	}
+	t.DownloadSize += r.DownloadSize
+	if installed {
+		// re-installing / upgrading replaces the local version
+		t.InstallSize += r.InstalledSize - r.LocalSize
+	} else {
+		t.InstallSize += r.InstalledSize
+	}
+}
+
+// adds packages to be removed. With recursive set, dependencies that are not needed anymore are removed as well
+func (t *transaction) addRemovals(installed map[string]orphanCandidate, remove []string, recursive bool) {
+	removed := map[string]bool{}
+	for _, name := range remove {
+		removed[name] = true
+		t.Remove = append(t.Remove, name)
+	}
+
+	// like "pacman -Rs": dependencies that were not installed explicitly and are only required by packages we remove
+	for changed := recursive; changed; {
+		changed = false
+		for name, c := range installed {
+			if removed[name] || !c.AsDependency || len(c.RequiredBy) == 0 {
+				continue
+			}
+			needed := false
+			for _, r := range c.RequiredBy {
+				if !removed[r] {
+					needed = true
+					break
+				}
+			}
+			if !needed {
+				removed[name] = true
+				t.Cascade = append(t.Cascade, name)
+				changed = true
+			}
+		}
+	}
+	sort.Strings(t.Cascade)
+
+	for name := range removed {
+		t.FreedSize += installed[name].Size
+		for _, r := range installed[name].RequiredBy {
+			if !removed[r] {
+				t.Breaks = append(t.Breaks, r+" requires "+name)
+			}
+		}
+	}
+	sort.Strings(t.Breaks)
+}
+
+// composes the text for our preview dialog
+func (t transaction) describe() string {
+	lines := []string{}
+	names := func(records []InfoRecord) string {
+		n := []string{}
+		for _, r := range records {
+			n = append(n, tview.Escape(r.Name+" ("+r.Source+")"))
+		}
+		return strings.Join(n, ", ")
+	}
+
+	if len(t.Install) > 0 {
+		lines = append(lines, fmt.Sprintf("[::b]Install (%d):[::-] %s", len(t.Install), names(t.Install)))
+	}
+	if len(t.Deps) > 0 {
+		lines = append(lines, fmt.Sprintf("[::b]Dependencies (%d):[::-] %s", len(t.Deps), names(t.Deps)))
+	}
+	if len(t.Remove) > 0 {
+		lines = append(lines, fmt.Sprintf("[::b]Remove (%d):[::-] %s", len(t.Remove), tview.Escape(strings.Join(t.Remove, ", "))))
+	}
+	if len(t.Cascade) > 0 {
+		lines = append(lines, fmt.Sprintf("[::b]Dependencies not needed anymore (%d):[::-] %s", len(t.Cascade), tview.Escape(strings.Join(t.Cascade, ", "))))
+	}
+	if len(t.Missing) > 0 {
+		lines = append(lines, "[red::b]Missing dependencies:[-::-] "+tview.Escape(strings.Join(t.Missing, ", ")))
+	}
+	if len(t.Conflicts) > 0 {
+		lines = append(lines, "[red::b]Conflicts:[-::-] "+tview.Escape(strings.Join(t.Conflicts, ", ")))
+	}
+	if len(t.Breaks) > 0 {
+		lines = append(lines, "[red::b]Breaks dependencies:[-::-] "+tview.Escape(strings.Join(t.Breaks, ", ")))
+	}
+
+	sizes := []string{}
+	if len(t.Install) > 0 {
+		sizes = append(sizes, "download size: "+formatSize(t.DownloadSize))
+	}
+	sizes = append(sizes, "net installed size: "+formatSizeDelta(t.InstallSize-t.FreedSize))
+	if t.Aur {
+		sizes = append(sizes, "without AUR packages")
+	}
+	lines = append(lines, "", "[::b]Sizes:[::-] "+strings.Join(sizes, ", "))
+
+	return strings.Join(lines, "\n")
+}
+
+// checks if an uninstall command removes dependencies as well, like "pacman -Rs" or "yay -Rns"
+func removesDependencies(command string) bool {
+	remove, recursive := false, false
+	for _, arg := range strings.Fields(command) {
+		switch {
+		case arg == "--remove":
+			remove = true
+		case arg == "--recursive":
+			recursive = true
+		case strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--"):
+			remove = remove || strings.Contains(arg, "R")
+			recursive = recursive || strings.Contains(arg, "s")
+		}
+	}
+	return remove && recursive
+}
+
+// appends values that are not part of a list yet
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !util.SliceContains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// returns conflicts declared by installed packages against packages we'd install
+func installedConflicts(local alpm.IDB, records []InfoRecord) []string {
+	conflicts := []string{}
+	for _, lpkg := range local.PkgCache().Slice() {
+		for _, c := range lpkg.Conflicts().Slice() {
+			name := depName(c.String())
+			for _, r := range records {
+				if r.Name == lpkg.Name() {
+					continue
+				}
+				if r.Name == name || containsDependency(r.Provides, name) {
+					conflicts = append(conflicts, r.Name+" <> "+lpkg.Name())
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// figures out what installing / removing packages would do
+func (ps *UI) getTransaction(ctx context.Context, install []InfoRecord, remove []InfoRecord) (transaction, error) {
+	t := transaction{}
+
+	trees := []*depNode{}
+	for _, pkg := range install {
+		trees = append(trees, ps.getDepTree(ctx, pkg, false))
+		if ctx.Err() != nil {
+			return t, ctx.Err()
+		}
+	}
+	t.addInstalls(trees)
+
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+	if len(install) > 0 {
+		local, err := ps.alpmHandle.LocalDB()
+		if err != nil {
+			return t, err
+		}
+		t.Conflicts = appendUnique(t.Conflicts, installedConflicts(local, append(t.Install, t.Deps...))...)
+	}
+	if len(remove) > 0 {
+		installed, err := localOrphanCandidates(ps.alpmHandle)
+		if err != nil {
+			return t, err
+		}
+		names := []string{}
+		for _, r := range remove {
+			names = append(names, r.Name)
+		}
+		t.addRemovals(installed, names, removesDependencies(ps.conf.UninstallCommand))
+	}
+	return t, nil
+}
+
+// shows a preview of what installing / removing packages would do and runs our command once confirmed
+func (ps *UI) previewTransaction(install []InfoRecord, remove []InfoRecord, run func()) {
+	if ps.conf.DisableTransactionPreview {
+		run()
+		return
+	}
+
+	ps.jobs.run(jobTransaction, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		t, err := ps.getTransaction(ctx, install, remove)
+		if ctx.Err() != nil {
+			return
+		}
+		ps.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ps.displayMessage(err.Error(), true)
+				return
+			}
+			ps.drawTransaction(t, run)
+		})
+	})
+}
+
+// draws our transaction preview dialog
+func (ps *UI) drawTransaction(t transaction, run func()) {
+	focused := ps.app.GetFocus()
+	close := func() {
+		ps.dialogVisible = false
+		ps.app.SetRoot(ps.flexRoot, true)
+		ps.app.SetFocus(focused)
+	}
+
+	text := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetText(t.describe())
+	text.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+
+	buttons := tview.NewForm().
+		AddButton("Proceed", func() {
+			close()
+			run()
+		}).
+		AddButton("Cancel", close).
+		SetButtonsAlign(tview.AlignCenter).
+		SetButtonBackgroundColor(ps.conf.Colors().SettingsFieldBackground).
+		SetButtonTextColor(ps.conf.Colors().SettingsFieldText).
+		SetCancelFunc(close)
+	buttons.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+
+	dialog := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(text, 0, 1, false).
+		AddItem(buttons, 3, 0, true)
+	dialog.SetBorder(true).
+		SetTitle(" [::b]Transaction preview ").
+		SetTitleColor(ps.conf.Colors().Title).
+		SetBorderPadding(1, 0, 1, 1).
+		SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+
+	// scroll the text with up / down while the buttons have focus
+	buttons.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			text.InputHandler()(event, nil)
+			return nil
+		}
+		return event
+	})
+
+	// center the dialog
+	root := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(dialog, 0, 3, true).
+			AddItem(nil, 0, 1, false), 0, 3, true).
+		AddItem(nil, 0, 1, false)
+	root.SetBackgroundColor(ps.conf.Colors().DefaultBackground)
+
+	ps.dialogVisible = true
+	ps.app.SetRoot(root, true).
+		SetFocus(buttons)
+}
@@ -1,6 +1,7 @@
 package pacseek
 
 import (
+	"context"
 	"io"
 	"runtime"
 	"sync"
@@ -20,7 +21,16 @@ const (
 	UrlPackage      = "https://archlinux.org/packages/%s/%s/%s"
 	UrlArmPackage   = "https://archlinuxarm.org/packages/%s/%s"
 	UrlRepoPkgbuild = "https://gitlab.archlinux.org/archlinux/packaging/packages/%s/-/raw/main/PKGBUILD"
-
+	UrlAurFile      = "https://aur.archlinux.org/cgit/aur.git/plain/%s?h=%s"
+	UrlAurTree      = "https://aur.archlinux.org/cgit/aur.git/tree/?h=%s"
+	UrlRepoFile     = "https://gitlab.archlinux.org/archlinux/packaging/packages/%s/-/raw/main/%s"
+	UrlAurLog       = "https://aur.archlinux.org/cgit/aur.git/log/?h=%s"
+	UrlAurPatch     = "https://aur.archlinux.org/cgit/aur.git/patch/?h=%s&id=%s"
+	UrlRepoCommits  = "https://gitlab.archlinux.org/api/v4/projects/archlinux%%2Fpackaging%%2Fpackages%%2F%s/repository/commits?per_page=100"
+	UrlRepoPatch    = "https://gitlab.archlinux.org/archlinux/packaging/packages/%s/-/commit/%s.patch"
+	UrlRepoTree     = "https://gitlab.archlinux.org/api/v4/projects/archlinux%%2Fpackaging%%2Fpackages%%2F%s/repository/tree?recursive=true&per_page=100"
+
+	UrlAurComments   = "https://aur.archlinux.org/packages/%s?O=0&PP=50"
 	UrlAurMaintainer = "https://aur.archlinux.org/packages?SeB=m&K=%s"
 
 	version = "1.8.6"
@@ -31,7 +41,9 @@ type UI struct {
 	conf *config.Settings
 	app  *tview.Application
 
-	alpmHandle *alpm.Handle
+	alpmHandle  *alpm.Handle
+	filesHandle *alpm.Handle // files DB's, synced on demand
+	filesSynced time.Time
 
 	flexRoot      *tview.Flex
 	flexLeft      *tview.Flex
@@ -39,20 +51,39 @@ type UI struct {
 	flexRight     *tview.Flex
 	flexContainer *tview.Flex
 
-	inputSearch   *tview.InputField
-	tablePackages *tview.Table
-	tableDetails  *tview.Table
-	spinner       *tview.TextView
-	formSettings  *tview.Form
-	textMessage   *tview.TextView
-	textPkgbuild  *tview.TextView
-	prevComponent tview.Primitive
-	tableNews     *tview.Table
-
-	locker        *sync.RWMutex
+	inputSearch    *tview.InputField
+	tablePackages  *tview.Table
+	tableDetails   *tview.Table
+	spinner        *tview.TextView
+	formSettings   *tview.Form
+	textMessage    *tview.TextView
+	textPkgbuild   *tview.TextView
+	textFiles      *tview.TextView
+	treeDeps       *tview.TreeView
+	flexBuild      *tview.Flex
+	textBuild      *tview.TextView
+	formBuild      *tview.Form
+	tableFindings  *tview.Table
+	tableRepoFiles *tview.Table
+	tableHistory   *tview.Table
+	textHistory    *tview.TextView
+	textComments   *tview.TextView
+	tableWatch     *tview.Table
+	reverseDeps    bool // treeDeps shows reverse dependencies
+	dialogVisible  bool // a dialog replaced our main layout
+	prevComponent  tview.Primitive
+	tableNews      *tview.Table
+	tableQueue     *tview.Table
+
+	locker        *sync.RWMutex // guards access to the alpm handle
 	messageLocker *sync.RWMutex
+	spinLocker    *sync.Mutex
+	dumpLocker    *sync.Mutex
+	filesLocker   *sync.Mutex // guards access to the files handle
+	jobs          *jobScheduler
 
 	quitSpin        chan bool
+	spinCount       int
 	width           int
 	leftProportion  int
 	selectedPackage *InfoRecord
@@ -60,11 +91,21 @@ type UI struct {
 	cacheInfo       *cache.Cache
 	cacheSearch     *cache.Cache
 	cachePkgbuild   *cache.Cache
+	cacheComments   *cache.Cache
 	filterRepos     []string
 	asciiMode       bool
 	shell           string
 	lastSearchTerm  string
 	shownPackages   []Package
+	orphansShown    bool // shownPackages is our list of orphaned packages
+	queue           []queuedPackage
+	sources         []PackageSource
+	columns         []packageColumn
+	lintRules       []lintRule
+	repoFiles       []string // files of the package shown in our PKGBUILD view
+	watchlist       *config.Watchlist
+	watchChanges    map[string][]string // changes of watched packages since the last check
+	aurDump         *aurDump
 	sortAscending   bool
 	isArm           bool
 	flags           args.Flags
@@ -72,6 +113,7 @@ type UI struct {
 	tableDetailsMore bool
 
 	pkgbuildWriter io.Writer
+	buildWriter    io.Writer
 }
 
 // New creates a UI object and makes sure everything is initialized
@@ -81,11 +123,17 @@ func New(conf *config.Settings, flags args.Flags) (*UI, error) {
 		app:             tview.NewApplication(),
 		locker:          &sync.RWMutex{},
 		messageLocker:   &sync.RWMutex{},
+		spinLocker:      &sync.Mutex{},
+		dumpLocker:      &sync.Mutex{},
+		filesLocker:     &sync.Mutex{},
+		jobs:            newJobScheduler(),
 		quitSpin:        make(chan bool),
 		settingsChanged: false,
 		cacheInfo:       cache.New(time.Duration(conf.CacheExpiry)*time.Minute, 1*time.Minute),
 		cacheSearch:     cache.New(time.Duration(conf.CacheExpiry)*time.Minute, 1*time.Minute),
 		cachePkgbuild:   cache.New(time.Duration(conf.CacheExpiry)*time.Minute, 1*time.Minute),
+		cacheComments:   cache.New(time.Duration(conf.CacheExpiry)*time.Minute, 1*time.Minute),
+		watchChanges:    map[string][]string{},
 
 		flags:         flags,
 		sortAscending: true,
@@ -102,6 +150,34 @@ func New(conf *config.Settings, flags args.Flags) (*UI, error) {
 		return nil, err
 	}
 
+	// set up our package sources
+	if err = ui.initSources(); err != nil {
+		return nil, err
+	}
+
+	// columns of our package list
+	if ui.columns, err = packageListColumns(conf.PackageListColumns); err != nil {
+		return nil, err
+	}
+
+	// rules for our PKGBUILD linter
+	if ui.lintRules, err = compileLintRules(conf.PkgbuildRules); err != nil {
+		return nil, err
+	}
+
+	// packages we get notified about when they change
+	if ui.watchlist, err = config.LoadWatchlist(); err != nil {
+		return nil, err
+	}
+
+	// restore cached data from disk; a broken cache file should not prevent us from starting
+	ui.loadCaches()
+
+	// load the AUR dump in the background so that our first search does not have to wait for it
+	if aur, ok := ui.sourceFor("AUR").(*aurSource); ok && conf.AurDataSource != aurSourceRpc {
+		go aur.dump(context.Background())
+	}
+
 	// set window layout
 	if conf.SaveWindowLayout {
 		if conf.LeftProportion < 1 || conf.LeftProportion > 9 {
@@ -144,7 +220,18 @@ func (ps *UI) Start() error {
 		}
 	}
 
-	return ps.app.SetRoot(ps.flexRoot, true).EnableMouse(true).Run()
+	ps.checkWatchlist()
+
+	err := ps.app.SetRoot(ps.flexRoot, true).EnableMouse(true).Run()
+
+	// persist cached data
+	if cerr := ps.saveCaches(); err == nil {
+		err = cerr
+	}
+	if ps.filesHandle != nil {
+		ps.filesHandle.Release()
+	}
+	return err
 }
 
 // getArchRepos returns a list of Arch Linux repositories
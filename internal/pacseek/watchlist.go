@@ -0,0 +1,207 @@
+package pacseek
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/moson-mo/pacseek/internal/config"
+	"github.com/rivo/tview"
+)
+
+// returns the changes of a watched package since the last check; cur is nil if the package does not exist anymore
+func watchChanges(old config.WatchedPackage, cur *InfoRecord) []string {
+	if cur == nil {
+		if old.Deleted {
+			return nil
+		}
+		return []string{"deleted"}
+	}
+	changes := []string{}
+	if old.Deleted {
+		changes = append(changes, "available again")
+	}
+	if old.Version != "" && old.Version != cur.Version {
+		changes = append(changes, "version "+old.Version+" -> "+cur.Version)
+	}
+	// for repository packages the maintainer is the packager of the latest build
+	switch {
+	case old.Source != "AUR", old.Maintainer == cur.Maintainer:
+	case cur.Maintainer == "":
+		changes = append(changes, "orphaned")
+	case old.Maintainer == "":
+		changes = append(changes, "adopted by "+cur.Maintainer)
+	default:
+		changes = append(changes, "maintainer "+old.Maintainer+" -> "+cur.Maintainer)
+	}
+	if old.OutOfDate == 0 && cur.OutOfDate != 0 {
+		changes = append(changes, "flagged out-of-date")
+	}
+	return changes
+}
+
+// returns the state of a package we store on our watchlist
+func watchedPackage(name, source string, cur *InfoRecord) config.WatchedPackage {
+	if cur == nil {
+		return config.WatchedPackage{Name: name, Source: source, Deleted: true}
+	}
+	return config.WatchedPackage{
+		Name:       name,
+		Source:     source,
+		Version:    cur.Version,
+		Maintainer: cur.Maintainer,
+		OutOfDate:  cur.OutOfDate,
+	}
+}
+
+// adds / removes the selected package to / from our watchlist
+func (ps *UI) toggleWatchSelected() {
+	row, _ := ps.tablePackages.GetSelection()
+	pkg, ok := ps.packageAt(row)
+	if !ok {
+		return
+	}
+	added := ps.watchlist.Toggle(config.WatchedPackage{
+		Name:       pkg.Name,
+		Source:     pkg.Source,
+		Version:    pkg.Version,
+		Maintainer: pkg.Maintainer,
+		OutOfDate:  pkg.OutOfDate,
+	})
+	delete(ps.watchChanges, pkg.Source+"/"+pkg.Name)
+	if err := ps.watchlist.Save(); err != nil {
+		ps.displayMessage(err.Error(), true)
+		return
+	}
+	if added {
+		ps.displayMessage(pkg.Name+" has been added to the watchlist", false)
+	} else {
+		ps.displayMessage(pkg.Name+" has been removed from the watchlist", false)
+	}
+	if ps.flexRight.GetItem(0) == ps.tableWatch {
+		ps.drawWatchlist()
+	}
+}
+
+// checks our watched packages for changes since the last check (e.g. on startup)
+func (ps *UI) checkWatchlist() {
+	if len(ps.watchlist.Packages) == 0 {
+		return
+	}
+	watched := append([]config.WatchedPackage{}, ps.watchlist.Packages...)
+
+	ps.jobs.run(jobWatch, func(ctx context.Context) {
+		ps.startSpinner()
+		defer ps.stopSpinner()
+
+		// one batch request per source
+		names := map[string][]string{}
+		for _, w := range watched {
+			names[w.Source] = append(names[w.Source], w.Name)
+		}
+		current := map[string]*InfoRecord{}
+		checked := map[string]bool{}
+		for source, pkgs := range names {
+			if ps.sourceFor(source) == nil {
+				continue
+			}
+			r := ps.getInfo(ctx, source, pkgs...)
+			if ctx.Err() != nil {
+				return
+			}
+			// we can't tell if packages have been deleted when the request failed
+			if r.Error != "" {
+				continue
+			}
+			checked[source] = true
+			for i := range r.Results {
+				current[source+"/"+r.Results[i].Name] = &r.Results[i]
+			}
+		}
+
+		ps.app.QueueUpdateDraw(func() {
+			changed := 0
+			for _, w := range watched {
+				i := ps.watchlist.Index(w.Name, w.Source)
+				if !checked[w.Source] || i == -1 {
+					continue
+				}
+				cur := current[w.Source+"/"+w.Name]
+				if changes := watchChanges(w, cur); len(changes) > 0 {
+					ps.watchChanges[w.Source+"/"+w.Name] = changes
+					changed++
+				}
+				ps.watchlist.Packages[i] = watchedPackage(w.Name, w.Source, cur)
+			}
+			if err := ps.watchlist.Save(); err != nil {
+				ps.displayMessage(err.Error(), true)
+				return
+			}
+			if changed > 0 {
+				ps.displayMessage(fmt.Sprintf("%d watched package(s) changed since the last check (ALT+W)", changed), false)
+			}
+			if ps.flexRight.GetItem(0) == ps.tableWatch {
+				ps.drawWatchlist()
+			}
+		})
+	})
+}
+
+// shows our watchlist
+func (ps *UI) displayWatchlist() {
+	ps.flexRight.Clear().
+		AddItem(ps.tableWatch, 0, 1, true)
+	ps.app.SetFocus(ps.tableWatch)
+	ps.drawWatchlist()
+}
+
+// draws our watchlist; packages that changed since the last check are highlighted
+func (ps *UI) drawWatchlist() {
+	ps.tableWatch.Clear().
+		SetTitle(fmt.Sprintf(" [::b]%sWatchlist (%d) ", ps.conf.Glyphs().Package, len(ps.watchlist.Packages)))
+
+	for i, col := range []string{"Name", "Source", "Version", "Out-of-date", "Maintainer", "Changes since last check"} {
+		ps.tableWatch.SetCell(0, i, &tview.TableCell{
+			Text:            col,
+			NotSelectable:   true,
+			Color:           ps.conf.Colors().PackagelistHeader,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+		})
+	}
+	for i, w := range ps.watchlist.Packages {
+		row := i + 1
+		color := tcell.ColorWhite
+		changes := ps.watchChanges[w.Source+"/"+w.Name]
+		if len(changes) > 0 {
+			color = tcell.ColorYellow
+		}
+		outOfDate, version, maintainer := "", w.Version, tview.Escape(w.Maintainer)
+		if w.OutOfDate != 0 {
+			outOfDate = "[red]" + time.Unix(int64(w.OutOfDate), 0).UTC().Format("2006-01-02") + "[-]"
+		}
+		if w.Deleted {
+			version = "[red]deleted[-]"
+		} else if maintainer == "" && w.Source == "AUR" {
+			maintainer = "[red]orphan[-]"
+		}
+		for col, text := range []string{tview.Escape(w.Name), w.Source, version, outOfDate, maintainer, tview.Escape(strings.Join(changes, ", "))} {
+			ps.tableWatch.SetCell(row, col, &tview.TableCell{
+				Text:            text,
+				Color:           color,
+				BackgroundColor: ps.conf.Colors().DefaultBackground,
+				Reference:       w,
+			})
+		}
+	}
+	if len(ps.watchlist.Packages) == 0 {
+		ps.tableWatch.SetCell(1, 0, &tview.TableCell{
+			Text:            "Press \"w\" in the package list to watch a package",
+			Color:           ps.conf.Colors().PackagelistHeader,
+			BackgroundColor: ps.conf.Colors().DefaultBackground,
+		})
+	}
+	ps.tableWatch.Select(1, 0).
+		ScrollToBeginning()
+}
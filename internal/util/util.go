@@ -2,6 +2,7 @@ package util
 
 import (
 	"os"
+	"path"
 )
 
 // SliceContains checks if a slice contains a certain element
@@ -62,3 +63,16 @@ func UniqueStrings(strSlices ...[]string) []string {
 
 	return result
 }
+
+// CacheDir returns (and creates if needed) our cache directory ($XDG_CACHE_HOME/pacseek)
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir = path.Join(dir, "pacseek")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -11,12 +11,21 @@ import (
 
 const helpText = `
 Usage: pacseek [OPTION] [SEARCH-TERM]
+       pacseek [OPTION] --json search|info|upgrades [ARGS]
 	-r 	Limit searching to a comma separated list of repositories
 	-s	Search-term
 	-a	ASCII mode
 	-m	Monochrome mode
 	-u	show upgrades after startup
 	-i	show installed packages after startup
+	--json	Run a query and print the results as JSON (no UI)
+
+Query commands (--json):
+	search TERM	Search for packages
+	info PKG...	Show package information
+	upgrades	List upgradable packages
+
+	Exit codes: 0 = found, 1 = nothing found, 2 = error
 
 Examples:
 
@@ -29,6 +38,9 @@ pacseek -ui
 pacseek pacseek
 -> Searches for "pacseek" in all repositories
 
+pacseek --json info yay pacman
+-> Prints package information for "yay" and "pacman" as JSON
+
 ----------------------------------------------------------------
 
 See also:
@@ -61,6 +73,9 @@ func main() {
 			printErrorExit("Error loading configuration file", err)
 		}
 	}
+	if f.Json {
+		os.Exit(pacseek.Query(conf, f, os.Stdout, os.Stderr))
+	}
 	ps, err := pacseek.New(conf, f)
 	if err != nil {
 		printErrorExit("Error during pacseek initialization", err)